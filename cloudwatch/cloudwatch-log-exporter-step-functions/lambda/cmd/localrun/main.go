@@ -0,0 +1,485 @@
+// Command localrun runs the export workflow of the state machine in-process:
+// reap expired leases, cancel stale tasks, list log groups (or enqueue a
+// backfill), then drain every region's lane and summarize the run. It uses the
+// same table, configuration parameter and notifiers as the deployed stack, so
+// it serves for ad-hoc exports from a laptop or a CI job and for debugging the
+// actions without deploying.
+//
+//	localrun -table LogGroupsTable -config /cloudwatch-log-exporter/config \
+//	    -regions us-east-1 -start 2024-05-01 -end 2024-05-03 -log-group /aws/lambda/api
+//
+// With -plan it only prints what a scheduled run would export, as JSON or a table:
+//
+//	localrun -table LogGroupsTable -config /cloudwatch-log-exporter/config -plan -format table
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda/messages"
+	"github.com/aws/aws-sdk-go-v2/config"
+
+	"lambda/exporter"
+)
+
+const (
+	// Throttled actions are retried like the state machine's throttledRetry does.
+	throttledAttempts = 5
+	throttledInterval = 30 * time.Second
+)
+
+// errRegionDeferred ends a lane whose region stayed busy, like the state
+// machine's RegionLaneDeferred state.
+var errRegionDeferred = errors.New("region deferred")
+
+type options struct {
+	regions   []string
+	account   string
+	logGroup  string
+	tags      map[string]string
+	startDate string
+	endDate   string
+	poll      time.Duration
+	notify    bool
+}
+
+func main() {
+	settings := exporter.SettingsFromEnv()
+	settings.Metrics = nil
+
+	var opts options
+	var regions, tags string
+	var metrics, plan bool
+	var format string
+	flag.StringVar(&settings.TableName, "table", settings.TableName, "log group table (DYNAMODB_TABLE_NAME)")
+	flag.StringVar(&settings.RunsTableName, "runs-table", settings.RunsTableName, "run history table, optional (RUNS_TABLE_NAME)")
+	flag.StringVar(&settings.ConfigParameter, "config", settings.ConfigParameter, "SSM parameter with the exporter configuration (SSM_PARAM_NAME)")
+	flag.StringVar(&settings.TopicArn, "topic", settings.TopicArn, "SNS topic notified when no notifiers are configured (SNS_TOPIC_ARN)")
+	flag.StringVar(&settings.MemberRoleName, "member-role", settings.MemberRoleName, "role assumed in member accounts without a roleName (MEMBER_ACCOUNT_ROLE_NAME)")
+	flag.StringVar(&regions, "regions", "", "comma-separated regions to export and clean up; all configured regions by default")
+	flag.StringVar(&opts.account, "account", "", "account of the backfill; the caller's account by default")
+	flag.StringVar(&opts.startDate, "start", "", "first day to export, YYYY-MM-DD; enqueues a backfill instead of listing log groups")
+	flag.StringVar(&opts.endDate, "end", "", "last day to export, YYYY-MM-DD; defaults to -start")
+	flag.StringVar(&opts.logGroup, "log-group", "", "log group to backfill")
+	flag.StringVar(&tags, "tags", "", "comma-separated key=value tags selecting the log groups to backfill")
+	flag.DurationVar(&opts.poll, "poll", 30*time.Second, "interval of export task status checks")
+	flag.BoolVar(&opts.notify, "notify", true, "send failure notifications and the run summary")
+	flag.BoolVar(&metrics, "metrics", false, "write Embedded Metric Format lines to stdout")
+	flag.BoolVar(&plan, "plan", false, "print what a run would export without writing to the table or creating export tasks")
+	flag.StringVar(&format, "format", "json", "output of -plan: json or table")
+	flag.Parse()
+
+	if regions != "" {
+		opts.regions = strings.Split(regions, ",")
+	}
+	if tags != "" {
+		opts.tags = map[string]string{}
+		for _, tag := range strings.Split(tags, ",") {
+			key, value, ok := strings.Cut(tag, "=")
+			if !ok {
+				log.Fatalf("Invalid tag %q, expected key=value", tag)
+			}
+			opts.tags[key] = value
+		}
+	}
+	if opts.endDate == "" {
+		opts.endDate = opts.startDate
+	}
+	if opts.startDate != "" && (len(opts.regions) == 0 || (opts.logGroup == "" && len(opts.tags) == 0)) {
+		log.Fatalf("A time range requires -regions and -log-group or -tags")
+	}
+	if settings.TableName == "" || settings.ConfigParameter == "" {
+		log.Fatalf("-table and -config are required")
+	}
+	if format != "json" && format != "table" {
+		log.Fatalf("Unknown -format %q, expected json or table", format)
+	}
+	if metrics {
+		settings.Metrics = os.Stdout
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		log.Fatalf("Failed to load AWS config: %v", err)
+	}
+	exporter.Init(cfg, settings)
+
+	if plan {
+		exportPlan, err := planExport(ctx, opts)
+		if err != nil {
+			log.Fatalf("Planning failed: %s", describe(err))
+		}
+		if format == "table" {
+			printPlan(exportPlan)
+		} else {
+			out, _ := json.MarshalIndent(exportPlan, "", "  ")
+			fmt.Println(string(out))
+		}
+		return
+	}
+
+	hostname, _ := os.Hostname()
+	started := time.Now().UTC()
+	run := workflow{
+		options: opts,
+		runID:   "local-" + started.Format("20060102T150405Z"),
+		owner:   "localrun:" + hostname,
+	}
+	report, err := run.execute(ctx, started)
+	if err != nil {
+		log.Fatalf("Run %s failed: %s", run.runID, describe(err))
+	}
+
+	out, _ := json.MarshalIndent(report, "", "  ")
+	fmt.Println(string(out))
+	if run.failedLanes > 0 {
+		os.Exit(1)
+	}
+}
+
+type workflow struct {
+	options
+	runID string
+	owner string
+
+	mu          sync.Mutex
+	failedLanes int
+}
+
+// execute runs the steps of the state machine and returns the run summary, or
+// the run report when notifications are off.
+func (w *workflow) execute(ctx context.Context, started time.Time) (interface{}, error) {
+	// The housekeeping steps act on the regions of the run only, so an ad-hoc run
+	// leaves the export tasks and leases of other regions alone
+	for _, region := range w.scope() {
+		if _, err := invoke[exporter.StaleTasksResult](ctx, exporter.Event{Action: "cancelStaleTasks", Region: region, Quiet: !w.notify}); err != nil {
+			return nil, err
+		}
+		if _, err := invoke[exporter.ReapResult](ctx, exporter.Event{Action: "reapExpiredLeases", Region: region}); err != nil {
+			return nil, err
+		}
+		if _, err := invoke[exporter.PreflightResult](ctx, exporter.Event{Action: "preflightBuckets", Region: region}); err != nil {
+			return nil, err
+		}
+	}
+
+	if w.startDate == "" {
+		for _, region := range w.scope() {
+			if _, err := invoke[exporter.SuccessResult](ctx, exporter.Event{Action: "listLogGroups", Region: region}); err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		for _, region := range w.regions {
+			result, err := invoke[exporter.BackfillResult](ctx, exporter.Event{
+				Action:       "backfill",
+				AccountId:    w.account,
+				Region:       region,
+				LogGroupName: w.logGroup,
+				Tags:         w.tags,
+				StartDate:    w.startDate,
+				EndDate:      w.endDate,
+			})
+			if err != nil {
+				return nil, err
+			}
+			log.Printf("Enqueued %d days of %d log groups in %s", result.Enqueued, len(result.LogGroups), region)
+		}
+	}
+
+	pending, err := invoke[exporter.PendingRegions](ctx, exporter.Event{Action: "listPendingRegions"})
+	if err != nil {
+		return nil, err
+	}
+
+	// Like the Map state, every region's lane runs concurrently
+	var wg sync.WaitGroup
+	for _, region := range pending.Regions {
+		if len(w.regions) > 0 && !slices.Contains(w.regions, region) {
+			continue
+		}
+		wg.Add(1)
+		go func(region string) {
+			defer wg.Done()
+			if err := w.lane(ctx, region); err != nil {
+				w.laneFailed(ctx, region, err)
+			}
+		}(region)
+	}
+	wg.Wait()
+
+	if !w.notify {
+		return invoke[exporter.RunReport](ctx, exporter.Event{Action: "getRunReport", RunId: w.runID})
+	}
+	return invoke[exporter.RunSummary](ctx, exporter.Event{
+		Action:       "summarizeRun",
+		RunId:        w.runID,
+		RunStartedAt: started.Format(time.RFC3339),
+	})
+}
+
+// scope returns the regions given with -regions, or a single empty region that
+// stands for all configured regions.
+func (w *workflow) scope() []string {
+	if len(w.regions) == 0 {
+		return []string{""}
+	}
+	return w.regions
+}
+
+// lane exports the pending log groups of a region one after the other.
+func (w *workflow) lane(ctx context.Context, region string) error {
+	for {
+		logGroup, err := invoke[*exporter.LogGroup](ctx, exporter.Event{Action: "getNextLogGroup", Region: region, Owner: w.owner})
+		if err != nil {
+			return err
+		}
+		if logGroup == nil {
+			log.Printf("Region %s is drained", region)
+			return nil
+		}
+		item := exporter.Event{
+			Owner:        w.owner,
+			RunId:        w.runID,
+			AccountId:    logGroup.AccountId,
+			LogGroupName: logGroup.LogGroupName,
+			ItemName:     logGroup.Name,
+			Region:       region,
+		}
+		if err := w.export(ctx, item); errors.Is(err, errRegionDeferred) {
+			log.Printf("Region %s is still busy, deferring its remaining log groups", region)
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+// export runs one claimed item through checkRunningTasks, createExportTask,
+// checkExportTaskStatus, writeManifest and updateDynamoDB.
+func (w *workflow) export(ctx context.Context, item exporter.Event) error {
+	for {
+		running, err := invoke[exporter.RunningTasks](ctx, withAction(item, "checkRunningTasks"))
+		if err != nil {
+			return err
+		}
+		if !running.TasksRunning {
+			break
+		}
+		if err := sleep(ctx, w.poll); err != nil {
+			return err
+		}
+	}
+
+	var created exporter.ExportTaskResult
+	for {
+		var err error
+		created, err = invoke[exporter.ExportTaskResult](ctx, withAction(item, "createExportTask"))
+		if err != nil {
+			return err
+		}
+		if created.Deferred {
+			return errRegionDeferred
+		}
+		if !created.Busy {
+			break
+		}
+		log.Printf("Region %s is busy with export task %s, waiting %ds", item.Region, created.BlockingTaskId, created.WaitSeconds)
+		if err := sleep(ctx, time.Duration(created.WaitSeconds)*time.Second); err != nil {
+			return err
+		}
+	}
+	if created.TaskId == "" {
+		return nil
+	}
+	item.TaskId = created.TaskId
+
+	var status exporter.ExportTaskStatus
+	for {
+		if err := sleep(ctx, w.poll); err != nil {
+			return err
+		}
+		var err error
+		status, err = invoke[exporter.ExportTaskStatus](ctx, withAction(item, "checkExportTaskStatus"))
+		if err != nil {
+			return err
+		}
+		if status.Status != nil && isFinal(string(status.Status.Code)) {
+			break
+		}
+	}
+	code := string(status.Status.Code)
+	log.Printf("Export task %s of %s ended %s", item.TaskId, item.LogGroupName, code)
+
+	if code == "COMPLETED" {
+		if _, err := invoke[exporter.ManifestResult](ctx, withAction(item, "writeManifest")); err != nil {
+			return err
+		}
+	}
+
+	update := withAction(item, "updateDynamoDB")
+	update.Status = code
+	if status.Status.Message != nil {
+		update.Message = *status.Status.Message
+	}
+	if status.StartTime != nil {
+		update.StartTime = *status.StartTime
+	}
+	if status.EndTime != nil {
+		update.EndTime = *status.EndTime
+	}
+	_, err := invoke[exporter.UpdateResult](ctx, update)
+	return err
+}
+
+// laneFailed reports a lane that stopped on an error, as the state machine's
+// SendLaneNotification does.
+func (w *workflow) laneFailed(ctx context.Context, region string, err error) {
+	w.mu.Lock()
+	w.failedLanes++
+	w.mu.Unlock()
+
+	message := fmt.Sprintf("Lane of region %s in run %s failed: %s", region, w.runID, describe(err))
+	log.Print(message)
+	if !w.notify {
+		return
+	}
+	if _, err := invoke[exporter.SuccessResult](ctx, exporter.Event{Action: "sendNotification", Message: message}); err != nil {
+		log.Printf("Error sending notification: %s", describe(err))
+	}
+}
+
+func withAction(event exporter.Event, action string) exporter.Event {
+	event.Action = action
+	return event
+}
+
+func isFinal(code string) bool {
+	switch code {
+	case "COMPLETED", "CANCELLED", "FAILED", "PENDING_CANCEL":
+		return true
+	}
+	return false
+}
+
+// invoke runs an action through the Lambda's handler and returns its result as
+// T. Throttled actions are retried with a doubling interval.
+func invoke[T any](ctx context.Context, event exporter.Event) (T, error) {
+	var zero T
+	interval := throttledInterval
+	for attempt := 1; ; attempt++ {
+		result, err := exporter.HandleRequest(ctx, event)
+		if err == nil {
+			typed, ok := result.(T)
+			if !ok {
+				return zero, fmt.Errorf("unexpected %T result of %s", result, event.Action)
+			}
+			return typed, nil
+		}
+
+		var invokeErr messages.InvokeResponse_Error
+		if !errors.As(err, &invokeErr) || invokeErr.Type != exporter.ErrThrottled || attempt == throttledAttempts {
+			return zero, err
+		}
+		log.Printf("%s was throttled, retrying in %s: %s", event.Action, interval, invokeErr.Message)
+		if err := sleep(ctx, interval); err != nil {
+			return zero, err
+		}
+		interval *= 2
+	}
+}
+
+// describe formats the errors of HandleRequest like Step Functions shows them.
+func describe(err error) string {
+	var invokeErr messages.InvokeResponse_Error
+	if errors.As(err, &invokeErr) {
+		return invokeErr.Type + ": " + invokeErr.Message
+	}
+	return err.Error()
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
+
+// planExport plans the given regions, or all configured ones.
+func planExport(ctx context.Context, opts options) (exporter.ExportPlan, error) {
+	if len(opts.regions) == 0 {
+		return invoke[exporter.ExportPlan](ctx, exporter.Event{Action: "planExport", AccountId: opts.account})
+	}
+
+	var merged exporter.ExportPlan
+	for _, region := range opts.regions {
+		plan, err := invoke[exporter.ExportPlan](ctx, exporter.Event{Action: "planExport", AccountId: opts.account, Region: region})
+		if err != nil {
+			return exporter.ExportPlan{}, err
+		}
+		if merged.GeneratedAt.IsZero() {
+			merged = plan
+			continue
+		}
+		merged.Planned += plan.Planned
+		merged.EstimatedBytes += plan.EstimatedBytes
+		merged.LogGroups = append(merged.LogGroups, plan.LogGroups...)
+		merged.Errors = append(merged.Errors, plan.Errors...)
+	}
+	return merged, nil
+}
+
+func printPlan(plan exporter.ExportPlan) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ACCOUNT\tREGION\tLOG GROUP\tFROM\tTO\tDESTINATION\tEST. SIZE\tNOTE")
+	for _, export := range plan.LogGroups {
+		if !export.Planned {
+			fmt.Fprintf(w, "%s\t%s\t%s\t-\t-\t-\t-\tskipped: %s\n", export.AccountId, export.Region, export.LogGroupName, export.SkipReason)
+			continue
+		}
+		note := ""
+		if export.Chunks > 1 {
+			note = fmt.Sprintf("%d chunks", export.Chunks)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\ts3://%s/%s\t%s\t%s\n",
+			export.AccountId, export.Region, export.LogGroupName,
+			export.From.Format(time.RFC3339), export.To.Format(time.RFC3339),
+			export.Bucket, strings.TrimPrefix(export.Prefix, "/"), formatBytes(export.EstimatedBytes), note)
+	}
+	w.Flush()
+
+	fmt.Printf("\n%d of %d log groups would be exported, about %s\n", plan.Planned, len(plan.LogGroups), formatBytes(plan.EstimatedBytes))
+	for _, problem := range plan.Errors {
+		fmt.Printf("Not planned: %s\n", problem)
+	}
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	value, exp := float64(n)/unit, 0
+	for value >= unit && exp < 4 {
+		value /= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", value, "KMGTP"[exp])
+}
@@ -0,0 +1,130 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+const roleSessionName = "cloudwatch-log-exporter"
+
+// AccountConfig names an account whose log groups are exported and the role
+// assumed in it. The role defaults to the deployment's member account role; the
+// exporter's own account uses the Lambda's credentials.
+type AccountConfig struct {
+	AccountId string `json:"accountId"`
+	RoleName  string `json:"roleName,omitempty"`
+}
+
+var (
+	callerAccountID   string
+	callerAccountOnce sync.Once
+)
+
+// getAccountID returns the account the exporter runs in, taken from the invoked
+// function ARN or, outside Lambda, from the caller identity.
+func getAccountID(ctx context.Context) string {
+	if lambdaContext, ok := lambdacontext.FromContext(ctx); ok {
+		arnParts := strings.Split(lambdaContext.InvokedFunctionArn, ":")
+		if len(arnParts) >= 5 {
+			return arnParts[4]
+		}
+	}
+
+	callerAccountOnce.Do(func() {
+		identity, err := stsClient.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+		if err != nil {
+			log.Printf("Could not retrieve caller identity: %v", err)
+			return
+		}
+		callerAccountID = aws.ToString(identity.Account)
+	})
+	return callerAccountID
+}
+
+// accounts returns the configured accounts, or the exporter's own account when
+// none are configured.
+func (c ExporterConfig) accounts(ctx context.Context) []AccountConfig {
+	if len(c.Accounts) == 0 {
+		return []AccountConfig{{AccountId: getAccountID(ctx)}}
+	}
+	return c.Accounts
+}
+
+func (c ExporterConfig) account(ctx context.Context, accountID string) (AccountConfig, error) {
+	if accountID == "" {
+		accountID = getAccountID(ctx)
+	}
+	for _, account := range c.accounts(ctx) {
+		if account.AccountId == accountID {
+			return account, nil
+		}
+	}
+	if accountID == getAccountID(ctx) {
+		return AccountConfig{AccountId: accountID}, nil
+	}
+	return AccountConfig{}, newError(ErrInvalidRequest, "account %s is not configured", accountID)
+}
+
+// loadAccountConfig returns an AWS config for a region, assuming the account's
+// role when one is configured.
+func loadAccountConfig(ctx context.Context, account AccountConfig, region string) (aws.Config, error) {
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	if err != nil {
+		return aws.Config{}, fmt.Errorf("error loading config for region %s: %w", region, err)
+	}
+	roleName := account.RoleName
+	if roleName == "" && account.AccountId != getAccountID(ctx) {
+		roleName = memberRole
+	}
+	if roleName == "" {
+		return cfg, nil
+	}
+
+	roleArn := fmt.Sprintf("arn:aws:iam::%s:role/%s", account.AccountId, roleName)
+	cfg.Credentials = aws.NewCredentialsCache(stscreds.NewAssumeRoleProvider(sts.NewFromConfig(cfg), roleArn, func(o *stscreds.AssumeRoleOptions) {
+		o.RoleSessionName = roleSessionName
+	}))
+	return cfg, nil
+}
+
+func newCWLogsClient(ctx context.Context, account AccountConfig, region string) (*cloudwatchlogs.Client, error) {
+	cfg, err := loadAccountConfig(ctx, account, region)
+	if err != nil {
+		return nil, err
+	}
+	return cloudwatchlogs.NewFromConfig(cfg), nil
+}
+
+// cwLogsClientFor resolves the account of an event or item and returns a
+// CloudWatch Logs client for it.
+func cwLogsClientFor(ctx context.Context, accountID, region string) (*cloudwatchlogs.Client, error) {
+	exporterConfig, err := getExporterConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	account, err := exporterConfig.account(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	return newCWLogsClient(ctx, account, region)
+}
+
+// itemName is the DynamoDB sort key of a log group. Log group names cannot
+// contain ':', so the account ID prefix is unambiguous.
+func itemName(accountID, logGroupName string) string {
+	return accountID + ":" + logGroupName
+}
+
+func logGroupArn(accountID, region, name string) string {
+	return fmt.Sprintf("arn:aws:logs:%s:%s:log-group:%s", region, accountID, name)
+}
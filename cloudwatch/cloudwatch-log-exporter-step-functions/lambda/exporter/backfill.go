@@ -0,0 +1,162 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dynamodbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const backfillDateLayout = "2006-01-02"
+
+type BackfillResult struct {
+	Enqueued int `json:"enqueued"`
+	// AlreadyEnqueued counts days whose item exists and has not been dead-lettered
+	AlreadyEnqueued int      `json:"alreadyEnqueued"`
+	LogGroups       []string `json:"logGroups"`
+}
+
+// backfill splits the inclusive date range [StartDate, EndDate] into one item per
+// day and log group. The items are picked up by the regular getNextLogGroup ->
+// createExportTask -> checkExportTaskStatus loop of the region's lane.
+func backfill(ctx context.Context, event Event) (BackfillResult, error) {
+	if event.Region == "" {
+		return BackfillResult{}, newError(ErrInvalidRequest, "backfill requires a region")
+	}
+	if event.LogGroupName == "" && len(event.Tags) == 0 {
+		return BackfillResult{}, newError(ErrInvalidRequest, "backfill requires a log group name or a tag selector")
+	}
+
+	start, err := time.Parse(backfillDateLayout, event.StartDate)
+	if err != nil {
+		return BackfillResult{}, newError(ErrInvalidRequest, "invalid startDate %q: %w", event.StartDate, err)
+	}
+	end, err := time.Parse(backfillDateLayout, event.EndDate)
+	if err != nil {
+		return BackfillResult{}, newError(ErrInvalidRequest, "invalid endDate %q: %w", event.EndDate, err)
+	}
+	if end.Before(start) {
+		return BackfillResult{}, newError(ErrInvalidRequest, "endDate %s is before startDate %s", event.EndDate, event.StartDate)
+	}
+
+	exporterConfig, err := getExporterConfig(ctx)
+	if err != nil {
+		return BackfillResult{}, err
+	}
+	if _, ok := exporterConfig.regionConfig(event.Region); !ok {
+		return BackfillResult{}, newError(ErrInvalidConfig, "no destination bucket found for region %s", event.Region)
+	}
+	account, err := exporterConfig.account(ctx, event.AccountId)
+	if err != nil {
+		return BackfillResult{}, err
+	}
+
+	logGroupNames := []string{event.LogGroupName}
+	if event.LogGroupName == "" {
+		logGroupNames, err = selectLogGroupsByTags(ctx, account, event.Region, event.Tags)
+		if err != nil {
+			return BackfillResult{}, err
+		}
+	}
+
+	// Only complete days can be exported
+	now := time.Now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	result := BackfillResult{LogGroups: logGroupNames}
+	for _, logGroupName := range logGroupNames {
+		for day := start; !day.After(end) && day.Before(today); day = day.AddDate(0, 0, 1) {
+			enqueued, err := putBackfillItem(ctx, account.AccountId, event.Region, logGroupName, day)
+			if err != nil {
+				return BackfillResult{}, err
+			}
+			if enqueued {
+				result.Enqueued++
+			} else {
+				result.AlreadyEnqueued++
+			}
+		}
+	}
+	log.Printf("Enqueued %d backfill items for %d log groups in region %s, %d were already enqueued", result.Enqueued, len(logGroupNames), event.Region, result.AlreadyEnqueued)
+
+	return result, nil
+}
+
+// selectLogGroupsByTags returns the log groups of an account and region carrying
+// all of the given tags.
+func selectLogGroupsByTags(ctx context.Context, account AccountConfig, region string, selector map[string]string) ([]string, error) {
+	cwLogsClient, err := newCWLogsClient(ctx, account, region)
+	if err != nil {
+		return nil, err
+	}
+
+	paginator := cloudwatchlogs.NewDescribeLogGroupsPaginator(cwLogsClient, &cloudwatchlogs.DescribeLogGroupsInput{})
+
+	logGroupNames := []string{}
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error listing log groups in region %s: %w", region, err)
+		}
+
+		for _, logGroup := range page.LogGroups {
+			name := aws.ToString(logGroup.LogGroupName)
+			tags := getLogGroupTags(ctx, cwLogsClient, logGroupArn(account.AccountId, region, name))
+			if matchesTags(tags, selector) {
+				logGroupNames = append(logGroupNames, name)
+			}
+		}
+	}
+	return logGroupNames, nil
+}
+
+func matchesTags(tags, selector map[string]string) bool {
+	for key, value := range selector {
+		if tags[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// putBackfillItem enqueues a one-day export of a log group. The item is keyed
+// separately from the scheduled item so it never touches its watermark. An
+// existing item is only replaced once dead-lettered, so a repeated backfill
+// neither resets an export in flight nor exports a completed day again; it
+// returns false for those.
+func putBackfillItem(ctx context.Context, accountID, region, logGroupName string, day time.Time) (bool, error) {
+	_, err := dynamoClient.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(tableName),
+		Item: map[string]dynamodbtypes.AttributeValue{
+			"Region":       &dynamodbtypes.AttributeValueMemberS{Value: region},
+			"Name":         &dynamodbtypes.AttributeValueMemberS{Value: fmt.Sprintf("%s#backfill#%s", itemName(accountID, logGroupName), day.Format(backfillDateLayout))},
+			"AccountId":    &dynamodbtypes.AttributeValueMemberS{Value: accountID},
+			"LogGroupName": &dynamodbtypes.AttributeValueMemberS{Value: logGroupName},
+			"Kind":         &dynamodbtypes.AttributeValueMemberS{Value: "BACKFILL"},
+			"ItemStatus":   &dynamodbtypes.AttributeValueMemberS{Value: "PENDING"},
+			"WindowFrom":   &dynamodbtypes.AttributeValueMemberS{Value: day.Format(time.RFC3339)},
+			"WindowTo":     &dynamodbtypes.AttributeValueMemberS{Value: day.AddDate(0, 0, 1).Format(time.RFC3339)},
+		},
+		ConditionExpression: aws.String("attribute_not_exists(#name) OR ItemStatus = :deadletter"),
+		ExpressionAttributeNames: map[string]string{
+			"#name": "Name",
+		},
+		ExpressionAttributeValues: map[string]dynamodbtypes.AttributeValue{
+			":deadletter": &dynamodbtypes.AttributeValueMemberS{Value: "DEAD_LETTER"},
+		},
+	})
+	if err != nil {
+		var conditionFailed *dynamodbtypes.ConditionalCheckFailedException
+		if errors.As(err, &conditionFailed) {
+			return false, nil
+		}
+		return false, fmt.Errorf("error writing backfill item for log group %s: %w", logGroupName, err)
+	}
+	return true, nil
+}
@@ -0,0 +1,82 @@
+package exporter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+// useDynamoDB points the DynamoDB client at a fake that answers every request
+// with the given error type, or succeeds without one. It returns the decoded
+// requests it received.
+func useDynamoDB(t *testing.T, errorType string) *[]map[string]interface{} {
+	t.Helper()
+	requests := []map[string]interface{}{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var input map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		requests = append(requests, input)
+		w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+		if errorType != "" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"__type": "com.amazonaws.dynamodb.v20120810#" + errorType, "message": errorType})
+			return
+		}
+		w.Write([]byte("{}"))
+	}))
+	t.Cleanup(server.Close)
+
+	previousClient, previousTable := dynamoClient, tableName
+	t.Cleanup(func() { dynamoClient, tableName = previousClient, previousTable })
+	dynamoClient = dynamodb.New(dynamodb.Options{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String(server.URL),
+		Credentials:  aws.AnonymousCredentials{},
+	})
+	tableName = "exports"
+	return &requests
+}
+
+func TestPutBackfillItem(t *testing.T) {
+	tests := []struct {
+		name      string
+		errorType string
+		want      bool
+		wantErr   bool
+	}{
+		{"enqueued", "", true, false},
+		{"already enqueued", "ConditionalCheckFailedException", false, false},
+		{"table missing", "ResourceNotFoundException", false, true},
+	}
+	day := time.Date(2026, 3, 7, 0, 0, 0, 0, time.UTC)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := useDynamoDB(t, tt.errorType)
+
+			got, err := putBackfillItem(context.Background(), "111111111111", "us-east-1", "/aws/lambda/app", day)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("putBackfillItem() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("putBackfillItem() = %v, want %v", got, tt.want)
+			}
+
+			if len(*requests) != 1 {
+				t.Fatalf("got %d requests, want 1", len(*requests))
+			}
+			// Only a missing or dead-lettered item may be replaced
+			if condition := (*requests)[0]["ConditionExpression"]; condition != "attribute_not_exists(#name) OR ItemStatus = :deadletter" {
+				t.Errorf("ConditionExpression = %v", condition)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dynamodbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	minBusyWait = 30 * time.Second
+	maxBusyWait = 5 * time.Minute
+	// maxBusyTotal caps how long one claim of an item waits for its region.
+	maxBusyTotal = time.Hour
+)
+
+// regionBusy is the result of createExportTask when CloudWatch Logs refused the
+// task because the account already has an active export in the region. The item
+// keeps its lease, renewed for the wait, and the state machine waits WaitSeconds
+// before trying again instead of failing the lane. Once the item has waited
+// maxBusyTotal since BusySince, it is Deferred instead: released to PENDING no
+// earlier than the next estimated wait, and the lane ends.
+func regionBusy(ctx context.Context, account AccountConfig, item LogGroup, event Event, result ExportTaskResult, now time.Time) (ExportTaskResult, error) {
+	wait := minBusyWait
+
+	tasks, err := describeActiveExportTasks(ctx, account, event.Region)
+	if err != nil {
+		log.Printf("Could not find the export task blocking region %s: %v", event.Region, err)
+	}
+	if blocking, ok := oldestExportTask(tasks); ok {
+		result.BlockingTaskId = aws.ToString(blocking.TaskId)
+		wait = busyWait(blocking, now)
+	}
+	putMetrics(map[string]string{"Action": "createExportTask", "Region": event.Region}, countMetric("ExportRegionBusy", 1))
+
+	busySince := item.BusySince
+	if busySince.IsZero() {
+		busySince = now
+	}
+	if now.Add(wait).Sub(busySince) > maxBusyTotal {
+		if err := deferItem(ctx, event.Region, event.itemKey(), event.Owner, now.Add(wait)); err != nil {
+			return ExportTaskResult{}, err
+		}
+		result.Deferred = true
+		log.Printf("Region %s is still busy with export task %s after %s, deferring %s", event.Region, result.BlockingTaskId, now.Sub(busySince).Round(time.Second), event.LogGroupName)
+		return result, nil
+	}
+
+	result.Busy = true
+	result.WaitSeconds = int(wait.Seconds())
+	if err := renewLease(ctx, event.Region, event.itemKey(), event.Owner, busySince, now.Add(wait+leaseDuration)); err != nil {
+		return ExportTaskResult{}, err
+	}
+
+	log.Printf("Region %s is busy with export task %s, retrying %s in %s", event.Region, result.BlockingTaskId, event.LogGroupName, wait)
+	return result, nil
+}
+
+// oldestExportTask returns the active task created first, which is the one
+// expected to finish first.
+func oldestExportTask(tasks []types.ExportTask) (types.ExportTask, bool) {
+	var oldest types.ExportTask
+	found := false
+	for _, task := range tasks {
+		if !found || creationTime(task) < creationTime(oldest) {
+			oldest, found = task, true
+		}
+	}
+	return oldest, found
+}
+
+func creationTime(task types.ExportTask) int64 {
+	if task.ExecutionInfo == nil {
+		return 0
+	}
+	return aws.ToInt64(task.ExecutionInfo.CreationTime)
+}
+
+// busyWait estimates how long a task keeps running. CloudWatch Logs does not
+// report progress, so a task is assumed to run about as long again as it has so
+// far, within minBusyWait and maxBusyWait.
+func busyWait(task types.ExportTask, now time.Time) time.Duration {
+	created := creationTime(task)
+	if created == 0 {
+		return minBusyWait
+	}
+	wait := now.Sub(time.UnixMilli(created)).Round(time.Second)
+	return min(max(wait, minBusyWait), maxBusyWait)
+}
+
+// renewLease extends the lease owner holds on an item to expiresAt and records
+// since when it waits for its region.
+func renewLease(ctx context.Context, region, name, owner string, busySince, expiresAt time.Time) error {
+	if owner == "" {
+		return nil
+	}
+	_, err := dynamoClient.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]dynamodbtypes.AttributeValue{
+			"Region": &dynamodbtypes.AttributeValueMemberS{Value: region},
+			"Name":   &dynamodbtypes.AttributeValueMemberS{Value: name},
+		},
+		UpdateExpression:    aws.String("SET LeaseExpiresAt = :expiry, BusySince = :since"),
+		ConditionExpression: aws.String("ItemStatus = :inprogress AND LeaseOwner = :owner"),
+		ExpressionAttributeValues: map[string]dynamodbtypes.AttributeValue{
+			":expiry":     &dynamodbtypes.AttributeValueMemberS{Value: expiresAt.UTC().Format(time.RFC3339)},
+			":since":      &dynamodbtypes.AttributeValueMemberS{Value: busySince.UTC().Format(time.RFC3339)},
+			":inprogress": &dynamodbtypes.AttributeValueMemberS{Value: "IN_PROGRESS"},
+			":owner":      &dynamodbtypes.AttributeValueMemberS{Value: owner},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("error renewing lease of log group %s: %w", name, err)
+	}
+	return nil
+}
+
+// deferItem releases an item owner holds back to PENDING, to be claimed no
+// earlier than notBefore. A deferral is not a failed attempt.
+func deferItem(ctx context.Context, region, name, owner string, notBefore time.Time) error {
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]dynamodbtypes.AttributeValue{
+			"Region": &dynamodbtypes.AttributeValueMemberS{Value: region},
+			"Name":   &dynamodbtypes.AttributeValueMemberS{Value: name},
+		},
+		UpdateExpression: aws.String("SET ItemStatus = :pending, NotBefore = :notbefore REMOVE LeaseOwner, LeaseExpiresAt, BusySince"),
+		ExpressionAttributeValues: map[string]dynamodbtypes.AttributeValue{
+			":pending":   &dynamodbtypes.AttributeValueMemberS{Value: "PENDING"},
+			":notbefore": &dynamodbtypes.AttributeValueMemberS{Value: notBefore.UTC().Format(time.RFC3339)},
+		},
+	}
+	leaseCondition(input, owner)
+	if _, err := dynamoClient.UpdateItem(ctx, input); err != nil {
+		return fmt.Errorf("error deferring log group %s: %w", name, err)
+	}
+	return nil
+}
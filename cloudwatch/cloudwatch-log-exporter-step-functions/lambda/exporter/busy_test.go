@@ -0,0 +1,66 @@
+package exporter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+)
+
+func exportTask(id string, created time.Time) types.ExportTask {
+	task := types.ExportTask{TaskId: aws.String(id)}
+	if !created.IsZero() {
+		task.ExecutionInfo = &types.ExportTaskExecutionInfo{CreationTime: aws.Int64(created.UnixMilli())}
+	}
+	return task
+}
+
+func TestBusyWait(t *testing.T) {
+	now := time.Date(2026, 10, 18, 0, 10, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		task types.ExportTask
+		want time.Duration
+	}{
+		{"unknown creation time", exportTask("a", time.Time{}), minBusyWait},
+		{"just created", exportTask("a", now.Add(-5*time.Second)), minBusyWait},
+		{"as long again", exportTask("a", now.Add(-2*time.Minute)), 2 * time.Minute},
+		{"rounded to seconds", exportTask("a", now.Add(-90*time.Second-400*time.Millisecond)), 90 * time.Second},
+		{"long running", exportTask("a", now.Add(-time.Hour)), maxBusyWait},
+		{"created after now", exportTask("a", now.Add(time.Minute)), minBusyWait},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := busyWait(tt.task, now); got != tt.want {
+				t.Errorf("busyWait() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOldestExportTask(t *testing.T) {
+	now := time.Date(2026, 10, 18, 0, 10, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		tasks  []types.ExportTask
+		want   string
+		wantOk bool
+	}{
+		{"none", nil, "", false},
+		{"one", []types.ExportTask{exportTask("a", now)}, "a", true},
+		{"oldest first", []types.ExportTask{exportTask("a", now.Add(-time.Minute)), exportTask("b", now)}, "a", true},
+		{"oldest last", []types.ExportTask{exportTask("a", now), exportTask("b", now.Add(-time.Minute))}, "b", true},
+		{"unknown creation time first", []types.ExportTask{exportTask("a", now), exportTask("b", time.Time{})}, "b", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := oldestExportTask(tt.tasks)
+			if aws.ToString(got.TaskId) != tt.want || ok != tt.wantOk {
+				t.Errorf("oldestExportTask() = %q, %v, want %q, %v", aws.ToString(got.TaskId), ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
@@ -0,0 +1,221 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dynamodbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	chunkSeparator = "#chunk#"
+	maxChunks      = 96
+)
+
+// chunkCount returns how many export tasks the window [from, to) is split into,
+// given the region's maximum chunk duration and size.
+func chunkCount(rc RegionConfig, group *types.LogGroup, from, to, now time.Time) int {
+	chunks := 1
+	if rc.MaxChunkHours > 0 {
+		chunks = int(math.Ceil(to.Sub(from).Hours() / float64(rc.MaxChunkHours)))
+	}
+
+	if windowBytes, ok := estimateWindowBytes(group, from, to, now); ok && rc.MaxChunkGiB > 0 {
+		windowGiB := float64(windowBytes) / (1 << 30)
+		if bySize := int(math.Ceil(windowGiB / float64(rc.MaxChunkGiB))); bySize > chunks {
+			chunks = bySize
+		}
+	}
+
+	return min(max(chunks, 1), maxChunks)
+}
+
+// estimateWindowBytes estimates the size of the window [from, to) from the
+// stored bytes of the log group, spread evenly over its retention period.
+func estimateWindowBytes(group *types.LogGroup, from, to, now time.Time) (int64, bool) {
+	if group == nil {
+		return 0, false
+	}
+	retention := now.Sub(time.UnixMilli(aws.ToInt64(group.CreationTime)))
+	if days := aws.ToInt32(group.RetentionInDays); days > 0 && time.Duration(days)*24*time.Hour < retention {
+		retention = time.Duration(days) * 24 * time.Hour
+	}
+	if retention <= 0 {
+		return 0, false
+	}
+	return int64(float64(aws.ToInt64(group.StoredBytes)) * to.Sub(from).Hours() / retention.Hours()), true
+}
+
+// splitWindow divides [from, to) into n adjacent ranges of whole minutes.
+func splitWindow(from, to time.Time, n int) [][2]time.Time {
+	step := (to.Sub(from) / time.Duration(n)).Truncate(time.Minute)
+	ranges := make([][2]time.Time, 0, n)
+	for i := 0; i < n; i++ {
+		chunkTo := from.Add(step)
+		if i == n-1 || step == 0 {
+			chunkTo = to
+		}
+		ranges = append(ranges, [2]time.Time{from, chunkTo})
+		if !chunkTo.Before(to) {
+			break
+		}
+		from = chunkTo
+	}
+	return ranges
+}
+
+// chunkLogGroup replaces the export of a scheduled item by one CHUNK item per
+// range. The chunks are exported like backfill items; the parent waits in
+// CHUNKED until every chunk has completed and then moves its watermark to the
+// end of the window.
+func chunkLogGroup(ctx context.Context, item LogGroup, ranges [][2]time.Time, owner string) error {
+	chunkNames := make([]string, 0, len(ranges))
+	for _, r := range ranges {
+		name := item.Name + chunkSeparator + r[0].UTC().Format(time.RFC3339)
+		chunkNames = append(chunkNames, name)
+
+		// Chunk names are stable for a window, so a retried split does not duplicate chunks
+		_, err := dynamoClient.PutItem(ctx, &dynamodb.PutItemInput{
+			TableName: aws.String(tableName),
+			Item: map[string]dynamodbtypes.AttributeValue{
+				"Region":       &dynamodbtypes.AttributeValueMemberS{Value: item.Region},
+				"Name":         &dynamodbtypes.AttributeValueMemberS{Value: name},
+				"AccountId":    &dynamodbtypes.AttributeValueMemberS{Value: item.AccountId},
+				"LogGroupName": &dynamodbtypes.AttributeValueMemberS{Value: item.LogGroupName},
+				"Kind":         &dynamodbtypes.AttributeValueMemberS{Value: "CHUNK"},
+				"ItemStatus":   &dynamodbtypes.AttributeValueMemberS{Value: "PENDING"},
+				"WindowFrom":   &dynamodbtypes.AttributeValueMemberS{Value: r[0].UTC().Format(time.RFC3339)},
+				"WindowTo":     &dynamodbtypes.AttributeValueMemberS{Value: r[1].UTC().Format(time.RFC3339)},
+			},
+			ConditionExpression: aws.String("attribute_not_exists(#name)"),
+			ExpressionAttributeNames: map[string]string{
+				"#name": "Name",
+			},
+		})
+		var conditionFailed *dynamodbtypes.ConditionalCheckFailedException
+		if err != nil && !errors.As(err, &conditionFailed) {
+			return fmt.Errorf("error writing chunk item %s: %w", name, err)
+		}
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]dynamodbtypes.AttributeValue{
+			"Region": &dynamodbtypes.AttributeValueMemberS{Value: item.Region},
+			"Name":   &dynamodbtypes.AttributeValueMemberS{Value: item.Name},
+		},
+		UpdateExpression: aws.String("SET ItemStatus = :chunked, PendingChunks = :chunks, ChunkedThrough = :through REMOVE LeaseOwner, LeaseExpiresAt"),
+		ExpressionAttributeValues: map[string]dynamodbtypes.AttributeValue{
+			":chunked": &dynamodbtypes.AttributeValueMemberS{Value: "CHUNKED"},
+			":chunks":  &dynamodbtypes.AttributeValueMemberSS{Value: chunkNames},
+			":through": &dynamodbtypes.AttributeValueMemberS{Value: ranges[len(ranges)-1][1].UTC().Format(time.RFC3339)},
+		},
+	}
+	leaseCondition(input, owner)
+	if _, err := dynamoClient.UpdateItem(ctx, input); err != nil {
+		return fmt.Errorf("error marking log group %s chunked: %w", item.Name, err)
+	}
+
+	log.Printf("Split export of %s into %d chunks", item.Name, len(chunkNames))
+	return nil
+}
+
+// completeChunk removes a finished chunk from its parent and completes the parent
+// once no chunk is left. Repeated calls for the same chunk are no-ops.
+func completeChunk(ctx context.Context, region, chunkName string) error {
+	parentName, _, ok := strings.Cut(chunkName, chunkSeparator)
+	if !ok {
+		return nil
+	}
+	key := map[string]dynamodbtypes.AttributeValue{
+		"Region": &dynamodbtypes.AttributeValueMemberS{Value: region},
+		"Name":   &dynamodbtypes.AttributeValueMemberS{Value: parentName},
+	}
+
+	output, err := dynamoClient.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(tableName),
+		Key:                 key,
+		UpdateExpression:    aws.String("DELETE PendingChunks :chunk"),
+		ConditionExpression: aws.String("contains(PendingChunks, :chunkname)"),
+		ExpressionAttributeValues: map[string]dynamodbtypes.AttributeValue{
+			":chunk":     &dynamodbtypes.AttributeValueMemberSS{Value: []string{chunkName}},
+			":chunkname": &dynamodbtypes.AttributeValueMemberS{Value: chunkName},
+		},
+		ReturnValues: dynamodbtypes.ReturnValueAllNew,
+	})
+	var conditionFailed *dynamodbtypes.ConditionalCheckFailedException
+	if errors.As(err, &conditionFailed) {
+		log.Printf("Chunk %s was already completed", chunkName)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error completing chunk %s: %w", chunkName, err)
+	}
+	if _, pending := output.Attributes["PendingChunks"]; pending {
+		return nil
+	}
+
+	// DynamoDB drops a set with its last element, so the parent is complete. A
+	// parent dead-lettered for one of its chunks completes once that chunk is
+	// retried successfully.
+	_, err = dynamoClient.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(tableName),
+		Key:                 key,
+		UpdateExpression:    aws.String("SET ItemStatus = :completed, ExportedThrough = ChunkedThrough, Attempts = :zero REMOVE ChunkedThrough, LastError"),
+		ConditionExpression: aws.String("ItemStatus IN (:chunked, :deadletter) AND attribute_exists(ChunkedThrough) AND attribute_not_exists(PendingChunks)"),
+		ExpressionAttributeValues: map[string]dynamodbtypes.AttributeValue{
+			":completed":  &dynamodbtypes.AttributeValueMemberS{Value: "COMPLETED"},
+			":chunked":    &dynamodbtypes.AttributeValueMemberS{Value: "CHUNKED"},
+			":deadletter": &dynamodbtypes.AttributeValueMemberS{Value: "DEAD_LETTER"},
+			":zero":       &dynamodbtypes.AttributeValueMemberN{Value: "0"},
+		},
+	})
+	if err != nil && !errors.As(err, &conditionFailed) {
+		return fmt.Errorf("error completing log group %s: %w", parentName, err)
+	}
+	log.Printf("All chunks of %s completed", parentName)
+	return nil
+}
+
+// deadLetterChunkParent moves the parent of a dead-lettered chunk from CHUNKED
+// to DEAD_LETTER, so it does not wait for the chunk forever. It returns the
+// parent's name and whether it was moved; items that are no chunks are left alone.
+func deadLetterChunkParent(ctx context.Context, region, chunkName, lastError string) (string, bool, error) {
+	parentName, _, ok := strings.Cut(chunkName, chunkSeparator)
+	if !ok {
+		return "", false, nil
+	}
+
+	_, err := dynamoClient.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]dynamodbtypes.AttributeValue{
+			"Region": &dynamodbtypes.AttributeValueMemberS{Value: region},
+			"Name":   &dynamodbtypes.AttributeValueMemberS{Value: parentName},
+		},
+		UpdateExpression:    aws.String("SET ItemStatus = :deadletter, LastError = :lasterror"),
+		ConditionExpression: aws.String("ItemStatus = :chunked AND contains(PendingChunks, :chunkname)"),
+		ExpressionAttributeValues: map[string]dynamodbtypes.AttributeValue{
+			":deadletter": &dynamodbtypes.AttributeValueMemberS{Value: "DEAD_LETTER"},
+			":chunked":    &dynamodbtypes.AttributeValueMemberS{Value: "CHUNKED"},
+			":chunkname":  &dynamodbtypes.AttributeValueMemberS{Value: chunkName},
+			":lasterror":  &dynamodbtypes.AttributeValueMemberS{Value: fmt.Sprintf("chunk %s was dead-lettered: %s", chunkName, lastError)},
+		},
+	})
+	var conditionFailed *dynamodbtypes.ConditionalCheckFailedException
+	if errors.As(err, &conditionFailed) {
+		return parentName, false, nil
+	}
+	if err != nil {
+		return parentName, false, fmt.Errorf("error dead-lettering log group %s: %w", parentName, err)
+	}
+	log.Printf("Moved %s to DEAD_LETTER, its chunk %s was dead-lettered", parentName, chunkName)
+	return parentName, true, nil
+}
@@ -0,0 +1,141 @@
+package exporter
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+)
+
+func TestChunkCount(t *testing.T) {
+	now := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+	day := now.AddDate(0, 0, -1)
+	// 100 GiB over 10 days of retention is 10 GiB a day
+	group := &types.LogGroup{
+		CreationTime:    aws.Int64(now.AddDate(-1, 0, 0).UnixMilli()),
+		RetentionInDays: aws.Int32(10),
+		StoredBytes:     aws.Int64(100 << 30),
+	}
+
+	tests := []struct {
+		name     string
+		config   RegionConfig
+		group    *types.LogGroup
+		from, to time.Time
+		want     int
+	}{
+		{"no limits", RegionConfig{}, group, day, now, 1},
+		{"by duration", RegionConfig{MaxChunkHours: 6}, group, day, now, 4},
+		{"partial chunk rounds up", RegionConfig{MaxChunkHours: 5}, group, day, now, 5},
+		{"by size", RegionConfig{MaxChunkGiB: 3}, group, day, now, 4},
+		{"larger of duration and size", RegionConfig{MaxChunkHours: 12, MaxChunkGiB: 3}, group, day, now, 4},
+		{"size within limit", RegionConfig{MaxChunkGiB: 20}, group, day, now, 1},
+		{"size of an unknown log group", RegionConfig{MaxChunkGiB: 1}, nil, day, now, 1},
+		{"capped", RegionConfig{MaxChunkHours: 1}, group, now.AddDate(0, 0, -30), now, maxChunks},
+		{
+			name:   "retention longer than the log group's age",
+			config: RegionConfig{MaxChunkGiB: 1},
+			group: &types.LogGroup{
+				CreationTime:    aws.Int64(now.AddDate(0, 0, -2).UnixMilli()),
+				RetentionInDays: aws.Int32(30),
+				StoredBytes:     aws.Int64(4 << 30),
+			},
+			from: day, to: now, want: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := chunkCount(tt.config, tt.group, tt.from, tt.to, now); got != tt.want {
+				t.Errorf("chunkCount() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitWindow(t *testing.T) {
+	from := time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) time.Time { return from.Add(d) }
+
+	tests := []struct {
+		name string
+		to   time.Time
+		n    int
+		want [][2]time.Time
+	}{
+		{"one", at(24 * time.Hour), 1, [][2]time.Time{{from, at(24 * time.Hour)}}},
+		{
+			name: "even",
+			to:   at(24 * time.Hour),
+			n:    3,
+			want: [][2]time.Time{{from, at(8 * time.Hour)}, {at(8 * time.Hour), at(16 * time.Hour)}, {at(16 * time.Hour), at(24 * time.Hour)}},
+		},
+		{
+			name: "last chunk takes the remainder",
+			to:   at(10 * time.Minute),
+			n:    3,
+			want: [][2]time.Time{{from, at(3 * time.Minute)}, {at(3 * time.Minute), at(6 * time.Minute)}, {at(6 * time.Minute), at(10 * time.Minute)}},
+		},
+		{
+			name: "window shorter than a minute per chunk",
+			to:   at(2 * time.Minute),
+			n:    4,
+			want: [][2]time.Time{{from, at(2 * time.Minute)}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitWindow(from, tt.to, tt.n)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("splitWindow() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeadLetterChunkParent(t *testing.T) {
+	tests := []struct {
+		name             string
+		itemName         string
+		errorType        string
+		wantParent       string
+		wantDeadLettered bool
+		wantRequests     int
+		wantErr          bool
+	}{
+		{"chunk", "111111111111:/app#chunk#2026-03-07T00:00:00Z", "", "111111111111:/app", true, 1, false},
+		{"parent no longer waiting", "111111111111:/app#chunk#2026-03-07T00:00:00Z", "ConditionalCheckFailedException", "111111111111:/app", false, 1, false},
+		{"table missing", "111111111111:/app#chunk#2026-03-07T00:00:00Z", "ResourceNotFoundException", "111111111111:/app", false, 1, true},
+		{"not a chunk", "111111111111:/app", "", "", false, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := useDynamoDB(t, tt.errorType)
+
+			parent, deadLettered, err := deadLetterChunkParent(context.Background(), "us-east-1", tt.itemName, "export task t-1 ended with status FAILED")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("deadLetterChunkParent() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if parent != tt.wantParent || deadLettered != tt.wantDeadLettered {
+				t.Errorf("deadLetterChunkParent() = %q, %v, want %q, %v", parent, deadLettered, tt.wantParent, tt.wantDeadLettered)
+			}
+			if len(*requests) != tt.wantRequests {
+				t.Fatalf("got %d requests, want %d", len(*requests), tt.wantRequests)
+			}
+			if tt.wantRequests == 0 {
+				return
+			}
+
+			request := (*requests)[0]
+			if name := request["Key"].(map[string]interface{})["Name"].(map[string]interface{})["S"]; name != tt.wantParent {
+				t.Errorf("updated %v, want the parent %s", name, tt.wantParent)
+			}
+			// Only a parent still waiting for the chunk is dead-lettered
+			if condition := request["ConditionExpression"]; condition != "ItemStatus = :chunked AND contains(PendingChunks, :chunkname)" {
+				t.Errorf("ConditionExpression = %v", condition)
+			}
+		})
+	}
+}
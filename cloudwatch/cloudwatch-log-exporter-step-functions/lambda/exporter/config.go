@@ -0,0 +1,365 @@
+package exporter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+
+	"prefix"
+)
+
+const (
+	exporterConfigVersion = 1
+	defaultPrefixTemplate = prefix.Default
+	// accountPrefixTemplate is the default once several accounts share a bucket
+	accountPrefixTemplate = "{account}/{logGroup}/{yyyy}/{mm}/{dd}"
+	// secretParameterPath is the path the exporter may read webhook secrets from
+	secretParameterPath = "/cloudwatch-log-exporter/"
+)
+
+var (
+	regionPattern    = regexp.MustCompile(`^[a-z]{2}(-[a-z]+)+-\d+$`)
+	bucketPattern    = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]{1,61}[a-z0-9]$`)
+	accountIDPattern = regexp.MustCompile(`^\d{12}$`)
+	kmsKeyArnPattern = regexp.MustCompile(`^arn:aws[a-z-]*:kms:([a-z0-9-]+):\d{12}:(key|alias)/.+$`)
+)
+
+// ExporterConfig is the versioned exporter configuration stored as JSON in the SSM
+// parameter. Top-level settings are defaults that each region may override.
+type ExporterConfig struct {
+	Version        int             `json:"version"`
+	ExportDays     int             `json:"exportDays,omitempty"`
+	PrefixTemplate string          `json:"prefixTemplate,omitempty"`
+	Include        []string        `json:"include,omitempty"`
+	Exclude        []string        `json:"exclude,omitempty"`
+	Rules          []SelectionRule `json:"rules,omitempty"`
+	Accounts       []AccountConfig `json:"accounts,omitempty"`
+	// MaxAttempts failed exports move a log group to DEAD_LETTER; retries wait
+	// RetryBaseDelayMinutes, doubled with every attempt.
+	MaxAttempts           int `json:"maxAttempts,omitempty"`
+	RetryBaseDelayMinutes int `json:"retryBaseDelayMinutes,omitempty"`
+	// Windows longer than MaxChunkHours or estimated larger than MaxChunkGiB are
+	// exported in chunks.
+	MaxChunkHours int `json:"maxChunkHours,omitempty"`
+	MaxChunkGiB   int `json:"maxChunkGiB,omitempty"`
+	// Export tasks PENDING or RUNNING for longer than MaxTaskAgeHours are
+	// cancelled by cancelStaleTasks.
+	MaxTaskAgeHours int                  `json:"maxTaskAgeHours,omitempty"`
+	Notifications   []NotificationTarget `json:"notifications,omitempty"`
+	Regions         []RegionConfig       `json:"regions"`
+
+	// include and exclude are the compiled Include and Exclude patterns.
+	include, exclude []SelectionRule
+}
+
+type RegionConfig struct {
+	Region         string `json:"region"`
+	Bucket         string `json:"bucket"`
+	PrefixTemplate string `json:"prefixTemplate,omitempty"`
+	// KmsKeyId is the SSE-KMS key the bucket encrypts exported objects with.
+	KmsKeyId      string          `json:"kmsKeyId,omitempty"`
+	Include       []string        `json:"include,omitempty"`
+	Exclude       []string        `json:"exclude,omitempty"`
+	Rules         []SelectionRule `json:"rules,omitempty"`
+	ExportDays    int             `json:"exportDays,omitempty"`
+	MaxChunkHours int             `json:"maxChunkHours,omitempty"`
+	MaxChunkGiB   int             `json:"maxChunkGiB,omitempty"`
+	// MaxTaskAgeHours defaults to the top-level setting, then to 12 hours.
+	MaxTaskAgeHours int `json:"maxTaskAgeHours,omitempty"`
+
+	include, exclude []SelectionRule
+}
+
+// NotificationTarget is an SNS topic or an HTTPS webhook. Severities limits the
+// target to notifications of those severities; empty means all of them.
+type NotificationTarget struct {
+	Type       string   `json:"type"`
+	Severities []string `json:"severities,omitempty"`
+	TopicArn   string   `json:"topicArn,omitempty"`
+	Url        string   `json:"url,omitempty"`
+	// BodyTemplate is a text/template rendering the JSON body of a webhook.
+	BodyTemplate string `json:"bodyTemplate,omitempty"`
+	// SecretParameter names the SecureString parameter with the HMAC secret the
+	// webhook body is signed with.
+	SecretParameter string `json:"secretParameter,omitempty"`
+}
+
+func getExporterConfig(ctx context.Context) (ExporterConfig, error) {
+	param, err := ssmClient.GetParameter(ctx, &ssm.GetParameterInput{
+		Name: aws.String(ssmParamName),
+	})
+	if err != nil {
+		return ExporterConfig{}, fmt.Errorf("failed to get SSM parameter: %w", err)
+	}
+
+	cfg, problems := parseExporterConfig(aws.ToString(param.Parameter.Value))
+	if len(problems) > 0 {
+		return ExporterConfig{}, newError(ErrInvalidConfig, "invalid exporter configuration in %s: %s", ssmParamName, strings.Join(problems, "; "))
+	}
+	return cfg, nil
+}
+
+func getRegionBucketMap(ctx context.Context) ([]RegionBucketMap, error) {
+	cfg, err := getExporterConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var regionBucketMap []RegionBucketMap
+	for _, rc := range cfg.Regions {
+		regionBucketMap = append(regionBucketMap, RegionBucketMap{
+			Region: rc.Region,
+			Bucket: rc.Bucket,
+		})
+	}
+	return regionBucketMap, nil
+}
+
+type ConfigValidation struct {
+	Valid    bool     `json:"valid"`
+	Version  int      `json:"version"`
+	Regions  int      `json:"regions"`
+	Problems []string `json:"problems"`
+}
+
+// validateConfig reports every problem of the stored configuration instead of
+// failing on the first one.
+func validateConfig(ctx context.Context) (ConfigValidation, error) {
+	param, err := ssmClient.GetParameter(ctx, &ssm.GetParameterInput{
+		Name: aws.String(ssmParamName),
+	})
+	if err != nil {
+		return ConfigValidation{}, fmt.Errorf("failed to get SSM parameter: %w", err)
+	}
+
+	cfg, problems := parseExporterConfig(aws.ToString(param.Parameter.Value))
+	if problems == nil {
+		problems = []string{}
+	}
+	return ConfigValidation{
+		Valid:    len(problems) == 0,
+		Version:  cfg.Version,
+		Regions:  len(cfg.Regions),
+		Problems: problems,
+	}, nil
+}
+
+// parseExporterConfig accepts the JSON document or, for existing deployments, the
+// legacy "region,bucket" lines. It returns all validation problems found.
+func parseExporterConfig(raw string) (ExporterConfig, []string) {
+	raw = strings.TrimSpace(raw)
+	if !strings.HasPrefix(raw, "{") {
+		return parseLegacyConfig(raw)
+	}
+
+	var cfg ExporterConfig
+	decoder := json.NewDecoder(strings.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&cfg); err != nil {
+		return cfg, []string{fmt.Sprintf("malformed JSON: %v", err)}
+	}
+	return cfg, cfg.validate()
+}
+
+func parseLegacyConfig(raw string) (ExporterConfig, []string) {
+	cfg := ExporterConfig{Version: exporterConfigVersion}
+	var problems []string
+	for i, line := range strings.Split(raw, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.Split(line, ",")
+		if len(parts) != 2 {
+			problems = append(problems, fmt.Sprintf("line %d: expected \"region,bucket\", got %q", i+1, line))
+			continue
+		}
+		cfg.Regions = append(cfg.Regions, RegionConfig{
+			Region: strings.TrimSpace(parts[0]),
+			Bucket: strings.TrimSpace(parts[1]),
+		})
+	}
+	return cfg, append(problems, cfg.validate()...)
+}
+
+// validate returns the problems of the configuration. It compiles the
+// selection rules and patterns, which are only matched once validated.
+func (c *ExporterConfig) validate() []string {
+	var problems []string
+	if c.Version != exporterConfigVersion {
+		problems = append(problems, fmt.Sprintf("unsupported version %d, expected %d", c.Version, exporterConfigVersion))
+	}
+	if c.ExportDays < 0 {
+		problems = append(problems, fmt.Sprintf("exportDays must not be negative, got %d", c.ExportDays))
+	}
+	if c.MaxAttempts < 0 || c.RetryBaseDelayMinutes < 0 {
+		problems = append(problems, "maxAttempts and retryBaseDelayMinutes must not be negative")
+	}
+	if c.MaxChunkHours < 0 || c.MaxChunkGiB < 0 {
+		problems = append(problems, "maxChunkHours and maxChunkGiB must not be negative")
+	}
+	if c.MaxTaskAgeHours < 0 {
+		problems = append(problems, fmt.Sprintf("maxTaskAgeHours must not be negative, got %d", c.MaxTaskAgeHours))
+	}
+	problems = append(problems, validatePrefixTemplate("prefixTemplate", c.PrefixTemplate)...)
+	var includeProblems, excludeProblems []string
+	c.include, includeProblems = compilePatterns("include", "include", c.Include)
+	c.exclude, excludeProblems = compilePatterns("exclude", "exclude", c.Exclude)
+	problems = append(problems, includeProblems...)
+	problems = append(problems, excludeProblems...)
+	problems = append(problems, validateRules("rules", c.Rules)...)
+
+	for i, target := range c.Notifications {
+		field := fmt.Sprintf("notifications[%d]", i)
+		switch target.Type {
+		case "sns":
+			if !strings.HasPrefix(target.TopicArn, "arn:") || !strings.Contains(target.TopicArn, ":sns:") {
+				problems = append(problems, fmt.Sprintf("%s: invalid SNS topic ARN %q", field, target.TopicArn))
+			}
+		case "webhook":
+			if u, err := url.Parse(target.Url); err != nil || u.Scheme != "https" || u.Host == "" {
+				problems = append(problems, fmt.Sprintf("%s: webhook url must be an https URL, got %q", field, target.Url))
+			}
+			if target.BodyTemplate != "" {
+				if _, err := parseWebhookBody(target.BodyTemplate); err != nil {
+					problems = append(problems, fmt.Sprintf("%s: invalid bodyTemplate: %v", field, err))
+				}
+			}
+			if target.SecretParameter != "" && !strings.HasPrefix(target.SecretParameter, secretParameterPath) {
+				problems = append(problems, fmt.Sprintf("%s: secretParameter must be below %s", field, secretParameterPath))
+			}
+		default:
+			problems = append(problems, fmt.Sprintf("%s: unsupported type %q", field, target.Type))
+		}
+		for _, severity := range target.Severities {
+			if severity != severityInfo && severity != severityWarning && severity != severityError {
+				problems = append(problems, fmt.Sprintf("%s: unknown severity %q", field, severity))
+			}
+		}
+	}
+
+	seenAccounts := map[string]bool{}
+	for i, account := range c.Accounts {
+		field := fmt.Sprintf("accounts[%d]", i)
+		if !accountIDPattern.MatchString(account.AccountId) {
+			problems = append(problems, fmt.Sprintf("%s: invalid account ID %q", field, account.AccountId))
+		} else if seenAccounts[account.AccountId] {
+			problems = append(problems, fmt.Sprintf("%s: duplicate account %s", field, account.AccountId))
+		}
+		seenAccounts[account.AccountId] = true
+		// The Lambda is only allowed to assume the member account role of its stack
+		if account.RoleName != "" && memberRole != "" && account.RoleName != memberRole {
+			problems = append(problems, fmt.Sprintf("%s: roleName must be %s, the member account role of the deployment, got %q", field, memberRole, account.RoleName))
+		}
+	}
+	if len(c.Accounts) > 1 && c.PrefixTemplate != "" && !strings.Contains(c.PrefixTemplate, "{account}") {
+		problems = append(problems, "prefixTemplate: must contain {account} when several accounts are configured")
+	}
+
+	if len(c.Regions) == 0 {
+		problems = append(problems, "no regions configured")
+	}
+	seen := map[string]bool{}
+	for i, rc := range c.Regions {
+		field := fmt.Sprintf("regions[%d]", i)
+		if !regionPattern.MatchString(rc.Region) {
+			problems = append(problems, fmt.Sprintf("%s: invalid region %q", field, rc.Region))
+		} else if seen[rc.Region] {
+			problems = append(problems, fmt.Sprintf("%s: duplicate region %s", field, rc.Region))
+		}
+		seen[rc.Region] = true
+
+		if !bucketPattern.MatchString(strings.TrimPrefix(rc.Bucket, "s3://")) {
+			problems = append(problems, fmt.Sprintf("%s: invalid bucket %q", field, rc.Bucket))
+		}
+		if rc.KmsKeyId != "" {
+			match := kmsKeyArnPattern.FindStringSubmatch(rc.KmsKeyId)
+			if match == nil {
+				problems = append(problems, fmt.Sprintf("%s: kmsKeyId must be a KMS key or alias ARN, got %q", field, rc.KmsKeyId))
+			} else if match[1] != rc.Region {
+				problems = append(problems, fmt.Sprintf("%s: kmsKeyId is in region %s, expected %s", field, match[1], rc.Region))
+			}
+		}
+		if rc.ExportDays < 0 {
+			problems = append(problems, fmt.Sprintf("%s: exportDays must not be negative, got %d", field, rc.ExportDays))
+		}
+		if rc.MaxChunkHours < 0 || rc.MaxChunkGiB < 0 {
+			problems = append(problems, fmt.Sprintf("%s: maxChunkHours and maxChunkGiB must not be negative", field))
+		}
+		if rc.MaxTaskAgeHours < 0 {
+			problems = append(problems, fmt.Sprintf("%s: maxTaskAgeHours must not be negative, got %d", field, rc.MaxTaskAgeHours))
+		}
+		problems = append(problems, validatePrefixTemplate(field+".prefixTemplate", rc.PrefixTemplate)...)
+		if len(c.Accounts) > 1 && rc.PrefixTemplate != "" && !strings.Contains(rc.PrefixTemplate, "{account}") {
+			problems = append(problems, fmt.Sprintf("%s.prefixTemplate: must contain {account} when several accounts are configured", field))
+		}
+		var includeProblems, excludeProblems []string
+		c.Regions[i].include, includeProblems = compilePatterns(field+".include", "include", rc.Include)
+		c.Regions[i].exclude, excludeProblems = compilePatterns(field+".exclude", "exclude", rc.Exclude)
+		problems = append(problems, includeProblems...)
+		problems = append(problems, excludeProblems...)
+		problems = append(problems, validateRules(field+".rules", rc.Rules)...)
+	}
+	return problems
+}
+
+func validatePrefixTemplate(field, template string) []string {
+	var problems []string
+	for _, problem := range prefix.Validate(template) {
+		problems = append(problems, fmt.Sprintf("%s: %s", field, problem))
+	}
+	return problems
+}
+
+// regionConfig returns the settings of a region with the top-level defaults applied.
+func (c ExporterConfig) regionConfig(region string) (RegionConfig, bool) {
+	for _, rc := range c.Regions {
+		if rc.Region != region {
+			continue
+		}
+		rc.Bucket = strings.TrimPrefix(rc.Bucket, "s3://")
+		if rc.PrefixTemplate == "" {
+			rc.PrefixTemplate = c.PrefixTemplate
+		}
+		if rc.PrefixTemplate == "" && len(c.Accounts) > 0 {
+			rc.PrefixTemplate = accountPrefixTemplate
+		}
+		if rc.PrefixTemplate == "" {
+			rc.PrefixTemplate = defaultPrefixTemplate
+		}
+		if rc.ExportDays == 0 {
+			rc.ExportDays = c.ExportDays
+		}
+		if rc.ExportDays == 0 {
+			rc.ExportDays = exportDays
+		}
+		if rc.MaxChunkHours == 0 {
+			rc.MaxChunkHours = c.MaxChunkHours
+		}
+		if rc.MaxChunkGiB == 0 {
+			rc.MaxChunkGiB = c.MaxChunkGiB
+		}
+		if rc.MaxTaskAgeHours == 0 {
+			rc.MaxTaskAgeHours = c.MaxTaskAgeHours
+		}
+		if rc.MaxTaskAgeHours == 0 {
+			rc.MaxTaskAgeHours = defaultMaxTaskAgeHours
+		}
+		if len(rc.Include) == 0 {
+			rc.Include, rc.include = c.Include, c.include
+		}
+		if len(rc.Exclude) == 0 {
+			rc.Exclude, rc.exclude = c.Exclude, c.exclude
+		}
+		// Region rules take precedence over the top-level rules
+		rc.Rules = append(append([]SelectionRule{}, rc.Rules...), c.Rules...)
+		return rc, true
+	}
+	return RegionConfig{}, false
+}
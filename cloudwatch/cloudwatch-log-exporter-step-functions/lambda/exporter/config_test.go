@@ -0,0 +1,263 @@
+package exporter
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestExporterConfigValidate(t *testing.T) {
+	valid := func() ExporterConfig {
+		return ExporterConfig{
+			Version: 1,
+			Regions: []RegionConfig{{Region: "us-east-1", Bucket: "s3://export-bucket"}},
+		}
+	}
+
+	tests := []struct {
+		name       string
+		memberRole string
+		edit       func(c *ExporterConfig)
+		want       []string
+	}{
+		{
+			name: "valid",
+			edit: func(c *ExporterConfig) {},
+		},
+		{
+			name: "bucket without scheme",
+			edit: func(c *ExporterConfig) { c.Regions[0].Bucket = "export-bucket" },
+		},
+		{
+			name: "unsupported version",
+			edit: func(c *ExporterConfig) { c.Version = 2 },
+			want: []string{"unsupported version 2, expected 1"},
+		},
+		{
+			name: "no regions",
+			edit: func(c *ExporterConfig) { c.Regions = nil },
+			want: []string{"no regions configured"},
+		},
+		{
+			name: "negative export days",
+			edit: func(c *ExporterConfig) {
+				c.ExportDays = -1
+				c.Regions[0].ExportDays = -2
+			},
+			want: []string{
+				"exportDays must not be negative, got -1",
+				"regions[0]: exportDays must not be negative, got -2",
+			},
+		},
+		{
+			name: "negative retry policy",
+			edit: func(c *ExporterConfig) { c.RetryBaseDelayMinutes = -5 },
+			want: []string{"maxAttempts and retryBaseDelayMinutes must not be negative"},
+		},
+		{
+			name: "negative chunk limits",
+			edit: func(c *ExporterConfig) {
+				c.MaxChunkGiB = -1
+				c.Regions[0].MaxChunkHours = -6
+			},
+			want: []string{
+				"maxChunkHours and maxChunkGiB must not be negative",
+				"regions[0]: maxChunkHours and maxChunkGiB must not be negative",
+			},
+		},
+		{
+			name: "negative task age",
+			edit: func(c *ExporterConfig) {
+				c.MaxTaskAgeHours = -1
+				c.Regions[0].MaxTaskAgeHours = -2
+			},
+			want: []string{
+				"maxTaskAgeHours must not be negative, got -1",
+				"regions[0]: maxTaskAgeHours must not be negative, got -2",
+			},
+		},
+		{
+			name: "invalid and duplicate regions",
+			edit: func(c *ExporterConfig) {
+				c.Regions = append(c.Regions,
+					RegionConfig{Region: "us-east-1", Bucket: "other-bucket"},
+					RegionConfig{Region: "useast1", Bucket: "other-bucket"})
+			},
+			want: []string{
+				"regions[1]: duplicate region us-east-1",
+				`regions[2]: invalid region "useast1"`,
+			},
+		},
+		{
+			name: "invalid bucket",
+			edit: func(c *ExporterConfig) { c.Regions[0].Bucket = "s3://Export_Bucket" },
+			want: []string{`regions[0]: invalid bucket "s3://Export_Bucket"`},
+		},
+		{
+			name: "KMS key of the region",
+			edit: func(c *ExporterConfig) {
+				c.Regions[0].KmsKeyId = "arn:aws:kms:us-east-1:111122223333:key/1234abcd-12ab-34cd-56ef-1234567890ab"
+			},
+		},
+		{
+			name: "KMS key of another region",
+			edit: func(c *ExporterConfig) {
+				c.Regions[0].KmsKeyId = "arn:aws:kms:eu-west-1:111122223333:alias/exports"
+			},
+			want: []string{"regions[0]: kmsKeyId is in region eu-west-1, expected us-east-1"},
+		},
+		{
+			name: "KMS key ID",
+			edit: func(c *ExporterConfig) { c.Regions[0].KmsKeyId = "1234abcd-12ab-34cd-56ef-1234567890ab" },
+			want: []string{`regions[0]: kmsKeyId must be a KMS key or alias ARN, got "1234abcd-12ab-34cd-56ef-1234567890ab"`},
+		},
+		{
+			name: "unknown prefix token",
+			edit: func(c *ExporterConfig) {
+				c.PrefixTemplate = "{logGroup}/{date}"
+				c.Regions[0].PrefixTemplate = "{stream}"
+			},
+			want: []string{
+				"prefixTemplate: unknown token {date}",
+				"regions[0].prefixTemplate: unknown token {stream}",
+			},
+		},
+		{
+			name: "empty patterns",
+			edit: func(c *ExporterConfig) {
+				c.Include = []string{" "}
+				c.Regions[0].Exclude = []string{"/aws/*", ""}
+			},
+			want: []string{
+				"include[0]: empty pattern",
+				"regions[0].exclude[1]: empty pattern",
+			},
+		},
+		{
+			name: "invalid rules",
+			edit: func(c *ExporterConfig) {
+				c.Rules = []SelectionRule{
+					{Name: "lambda", Action: "include", NamePrefix: "/aws/lambda/"},
+					{Name: "lambda", Action: "skip", NameRegex: "("},
+					{Action: "exclude"},
+				}
+			},
+			want: []string{
+				`rules[1]: duplicate rule name "lambda"`,
+				`rules[1]: action must be include or exclude, got "skip"`,
+				"rules[1]: invalid nameRegex: error parsing regexp: missing closing ): `(`",
+				"rules[2]: missing name",
+				"rules[2]: rule has no conditions",
+			},
+		},
+		{
+			name: "invalid rule conditions",
+			edit: func(c *ExporterConfig) {
+				c.Regions[0].Rules = []SelectionRule{
+					{Name: "tags", Action: "exclude", Tags: []string{"=prod", "!"}},
+					{Name: "class", Action: "exclude", LogGroupClass: "ARCHIVE"},
+					{Name: "age", Action: "exclude", MinAgeDays: 10, MaxAgeDays: 5},
+				}
+			},
+			want: []string{
+				`regions[0].rules[0]: invalid tag expression "=prod"`,
+				`regions[0].rules[0]: invalid tag expression "!"`,
+				`regions[0].rules[1]: unknown logGroupClass "ARCHIVE"`,
+				"regions[0].rules[2]: minAgeDays is greater than maxAgeDays",
+			},
+		},
+		{
+			name: "notification targets",
+			edit: func(c *ExporterConfig) {
+				c.Notifications = []NotificationTarget{
+					{Type: "sns", TopicArn: "arn:aws:sns:us-east-1:111122223333:exports"},
+					{Type: "sns", TopicArn: "exports"},
+					{Type: "webhook", Url: "http://example.com/hook", SecretParameter: "/other/secret", Severities: []string{"DEBUG"}},
+					{Type: "email"},
+				}
+			},
+			want: []string{
+				`notifications[1]: invalid SNS topic ARN "exports"`,
+				`notifications[2]: webhook url must be an https URL, got "http://example.com/hook"`,
+				"notifications[2]: secretParameter must be below /cloudwatch-log-exporter/",
+				`notifications[2]: unknown severity "DEBUG"`,
+				`notifications[3]: unsupported type "email"`,
+			},
+		},
+		{
+			name: "invalid and duplicate accounts",
+			edit: func(c *ExporterConfig) {
+				c.PrefixTemplate = "{account}/{logGroup}"
+				c.Accounts = []AccountConfig{{AccountId: "111122223333"}, {AccountId: "111122223333"}, {AccountId: "1234"}}
+			},
+			want: []string{
+				"accounts[1]: duplicate account 111122223333",
+				`accounts[2]: invalid account ID "1234"`,
+			},
+		},
+		{
+			name: "several accounts without {account}",
+			edit: func(c *ExporterConfig) {
+				c.PrefixTemplate = "{logGroup}"
+				c.Regions[0].PrefixTemplate = "{logGroup}/{yyyy}"
+				c.Accounts = []AccountConfig{{AccountId: "111122223333"}, {AccountId: "444455556666"}}
+			},
+			want: []string{
+				"prefixTemplate: must contain {account} when several accounts are configured",
+				"regions[0].prefixTemplate: must contain {account} when several accounts are configured",
+			},
+		},
+		{
+			name:       "member account role of the deployment",
+			memberRole: "ExporterRole",
+			edit: func(c *ExporterConfig) {
+				c.Accounts = []AccountConfig{{AccountId: "111122223333", RoleName: "ExporterRole"}, {AccountId: "444455556666", RoleName: "AdminRole"}}
+				c.PrefixTemplate = "{account}/{logGroup}"
+			},
+			want: []string{`accounts[1]: roleName must be ExporterRole, the member account role of the deployment, got "AdminRole"`},
+		},
+		{
+			name: "any role without a member account role",
+			edit: func(c *ExporterConfig) {
+				c.Accounts = []AccountConfig{{AccountId: "111122223333", RoleName: "AdminRole"}}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func(role string) { memberRole = role }(memberRole)
+			memberRole = tt.memberRole
+
+			c := valid()
+			tt.edit(&c)
+			if got := c.validate(); !slices.Equal(got, tt.want) {
+				t.Errorf("validate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseExporterConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		regions int
+		want    []string
+	}{
+		{"JSON", `{"version": 1, "regions": [{"region": "us-east-1", "bucket": "s3://export-bucket"}]}`, 1, nil},
+		{"unknown field", `{"version": 1, "region": "us-east-1"}`, 0, []string{`malformed JSON: json: unknown field "region"`}},
+		{"legacy lines", "# region,bucket\nus-east-1,s3://aaa\n\n us-east-2 , bbb-bucket \n", 2, nil},
+		{"legacy line without bucket", "us-east-1,s3://aaa\nus-east-2", 1, []string{`line 2: expected "region,bucket", got "us-east-2"`}},
+		{"empty", "", 0, []string{"no regions configured"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, problems := parseExporterConfig(tt.raw)
+			if !slices.Equal(problems, tt.want) {
+				t.Errorf("parseExporterConfig() problems = %q, want %q", problems, tt.want)
+			}
+			if len(cfg.Regions) != tt.regions {
+				t.Errorf("parseExporterConfig() regions = %d, want %d", len(cfg.Regions), tt.regions)
+			}
+		})
+	}
+}
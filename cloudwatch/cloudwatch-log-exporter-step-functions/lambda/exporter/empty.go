@@ -0,0 +1,119 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dynamodbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// lastEventLag is how long CloudWatch Logs may take to reflect ingested events
+// in StoredBytes and LastEventTimestamp.
+const lastEventLag = time.Hour
+
+// describeLogGroup returns the log group with exactly the given name, or nil
+// when it does not exist.
+func describeLogGroup(ctx context.Context, cwLogsClient *cloudwatchlogs.Client, logGroupName string) (*types.LogGroup, error) {
+	output, err := cwLogsClient.DescribeLogGroups(ctx, &cloudwatchlogs.DescribeLogGroupsInput{
+		LogGroupNamePrefix: aws.String(logGroupName),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error describing log group %s: %w", logGroupName, err)
+	}
+	for _, group := range output.LogGroups {
+		if aws.ToString(group.LogGroupName) == logGroupName {
+			return &group, nil
+		}
+	}
+	return nil, nil
+}
+
+// emptyWindowReason tells why the window [from, to) of a log group holds no
+// events, or returns an empty reason when it may hold some. Since the metadata
+// lags behind ingestion, the returned time is how far the window is known to be
+// empty.
+func emptyWindowReason(ctx context.Context, cwLogsClient *cloudwatchlogs.Client, group *types.LogGroup, from, to, now time.Time) (string, time.Time, error) {
+	through := to
+	if settled := now.Add(-lastEventLag); settled.Before(through) {
+		through = settled
+	}
+	if group == nil || !from.Before(through) {
+		return "", time.Time{}, nil
+	}
+	if aws.ToInt64(group.StoredBytes) == 0 {
+		return "log group stores no data", through, nil
+	}
+	logGroupName := aws.ToString(group.LogGroupName)
+
+	streams, err := cwLogsClient.DescribeLogStreams(ctx, &cloudwatchlogs.DescribeLogStreamsInput{
+		LogGroupName: aws.String(logGroupName),
+		OrderBy:      types.OrderByLastEventTime,
+		Descending:   aws.Bool(true),
+		Limit:        aws.Int32(1),
+	})
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("error describing log streams of %s: %w", logGroupName, err)
+	}
+	if len(streams.LogStreams) == 0 {
+		return "log group has no log streams", through, nil
+	}
+
+	lastEvent := streams.LogStreams[0].LastEventTimestamp
+	if lastEvent == nil {
+		return "log group has no events", through, nil
+	}
+	lastEventTime := time.UnixMilli(*lastEvent).UTC()
+	if lastEventTime.Before(from) {
+		return fmt.Sprintf("newest event at %s is before the export window", lastEventTime.Format(time.RFC3339)), through, nil
+	}
+	return "", time.Time{}, nil
+}
+
+// skipEmptyWindow marks an item SKIPPED_EMPTY instead of exporting it. Scheduled
+// items move their watermark past the empty range, see skippedThrough; backfill
+// items keep their window.
+func skipEmptyWindow(ctx context.Context, item LogGroup, reason string, through time.Time) error {
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]dynamodbtypes.AttributeValue{
+			"Region": &dynamodbtypes.AttributeValueMemberS{Value: item.Region},
+			"Name":   &dynamodbtypes.AttributeValueMemberS{Value: item.Name},
+		},
+		UpdateExpression: aws.String("SET ItemStatus = :skipped, SkipReason = :reason REMOVE LeaseOwner, LeaseExpiresAt"),
+		ExpressionAttributeValues: map[string]dynamodbtypes.AttributeValue{
+			":skipped": &dynamodbtypes.AttributeValueMemberS{Value: "SKIPPED_EMPTY"},
+			":reason":  &dynamodbtypes.AttributeValueMemberS{Value: reason},
+		},
+	}
+	if watermark := skippedThrough(item.ExportedThrough, through); item.WindowFrom.IsZero() && watermark.After(item.ExportedThrough) {
+		through = watermark
+		input.UpdateExpression = aws.String("SET ItemStatus = :skipped, SkipReason = :reason, ExportedThrough = :through REMOVE LeaseOwner, LeaseExpiresAt")
+		input.ExpressionAttributeValues[":through"] = &dynamodbtypes.AttributeValueMemberS{Value: through.UTC().Format(time.RFC3339)}
+	}
+
+	_, err := dynamoClient.UpdateItem(ctx, input)
+	if err != nil {
+		return fmt.Errorf("error skipping log group %s: %w", item.Name, err)
+	}
+	log.Printf("Skipped %s through %s: %s", item.Name, through.Format(time.RFC3339), reason)
+	return nil
+}
+
+// skippedThrough is the watermark of a scheduled item whose window is known to
+// be empty up to through. The prefix of the next window is expanded from the
+// watermark, so it stays on a UTC day boundary: through is truncated to the
+// start of its day, and the watermark never moves back.
+func skippedThrough(exportedThrough, through time.Time) time.Time {
+	through = through.UTC()
+	day := time.Date(through.Year(), through.Month(), through.Day(), 0, 0, 0, 0, time.UTC)
+	if day.After(exportedThrough) {
+		return day
+	}
+	return exportedThrough
+}
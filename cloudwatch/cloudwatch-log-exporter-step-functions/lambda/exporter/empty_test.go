@@ -0,0 +1,31 @@
+package exporter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSkippedThrough(t *testing.T) {
+	day := time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name            string
+		exportedThrough time.Time
+		through         time.Time
+		want            time.Time
+	}{
+		{"day boundary", day.AddDate(0, 0, -1), day, day},
+		{"within a day", day.AddDate(0, 0, -3), day.Add(15*time.Hour + 20*time.Minute), day},
+		{"other zone", day.AddDate(0, 0, -3), day.Add(time.Hour).In(time.FixedZone("UTC-5", -5*60*60)), day},
+		{"within the watermark's day", day, day.Add(6 * time.Hour), day},
+		{"never moves back", day, day.AddDate(0, 0, -2), day},
+		{"never exported", time.Time{}, day.Add(time.Hour), day},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := skippedThrough(tt.exportedThrough, tt.through); !got.Equal(tt.want) {
+				t.Errorf("skippedThrough() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package exporter
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/lambda/messages"
+	"github.com/aws/smithy-go"
+)
+
+// Error names reported to Step Functions as the Lambda error type. Retry and
+// Catch rules of the state machine match on them, so they must not change.
+const (
+	// ErrThrottled is a limit or throttle that goes away when retried later.
+	ErrThrottled = "Exporter.Throttled"
+	// ErrInvalidRequest is an event or parameter that will never succeed.
+	ErrInvalidRequest = "Exporter.InvalidRequest"
+	// ErrInvalidConfig is a missing or invalid exporter configuration.
+	ErrInvalidConfig = "Exporter.InvalidConfig"
+	// ErrDestinationUnavailable is a bucket or KMS key exports cannot be written to.
+	ErrDestinationUnavailable = "Exporter.DestinationUnavailable"
+	// ErrAccessDenied is a missing permission, e.g. of an account's export role.
+	ErrAccessDenied = "Exporter.AccessDenied"
+	// ErrNotFound is a log group, export task or item that does not exist.
+	ErrNotFound = "Exporter.NotFound"
+	// ErrConflict is a write that lost a race, e.g. against a lease of another execution.
+	ErrConflict = "Exporter.Conflict"
+	// ErrInternal is every other failure.
+	ErrInternal = "Exporter.Internal"
+)
+
+// ExporterError carries the kind of a failure that the AWS error it wraps, if
+// any, does not tell.
+type ExporterError struct {
+	Kind string
+	Err  error
+}
+
+func (e *ExporterError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExporterError) Unwrap() error {
+	return e.Err
+}
+
+func newError(kind, format string, args ...interface{}) error {
+	return &ExporterError{Kind: kind, Err: fmt.Errorf(format, args...)}
+}
+
+// errorKind classifies an error by the ExporterError or AWS API error in its chain.
+func errorKind(err error) string {
+	var exporterErr *ExporterError
+	if errors.As(err, &exporterErr) {
+		return exporterErr.Kind
+	}
+
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return ErrInternal
+	}
+	code := apiErr.ErrorCode()
+	switch {
+	case code == "LimitExceededException", code == "ThrottlingException", code == "Throttling",
+		code == "TooManyRequestsException", code == "ProvisionedThroughputExceededException",
+		code == "RequestLimitExceeded", code == "SlowDown", code == "OperationAbortedException",
+		code == "ServiceUnavailableException", code == "ServiceUnavailable":
+		return ErrThrottled
+	case code == "NoSuchBucket", strings.HasPrefix(code, "KMS."):
+		// Only S3 uses these codes, for the destination bucket
+		return ErrDestinationUnavailable
+	case code == "AccessDenied":
+		// STS denies assuming a member account role with the same code as S3
+		if serviceID(err) == "S3" {
+			return ErrDestinationUnavailable
+		}
+		return ErrAccessDenied
+	case code == "InvalidParameterException", code == "ValidationException":
+		// CloudWatch Logs reports buckets it cannot write to as an invalid parameter
+		if strings.Contains(strings.ToLower(apiErr.ErrorMessage()), "bucket") {
+			return ErrDestinationUnavailable
+		}
+		return ErrInvalidRequest
+	case code == "AccessDeniedException", code == "UnauthorizedOperation":
+		return ErrAccessDenied
+	case code == "ParameterNotFound":
+		return ErrInvalidConfig
+	case code == "ResourceNotFoundException":
+		return ErrNotFound
+	case code == "ConditionalCheckFailedException", code == "TransactionConflictException":
+		return ErrConflict
+	}
+	return ErrInternal
+}
+
+// serviceID returns the service of the outermost AWS operation in the chain.
+func serviceID(err error) string {
+	var opErr *smithy.OperationError
+	if errors.As(err, &opErr) {
+		return opErr.ServiceID
+	}
+	return ""
+}
+
+// lambdaError returns err as the error the Lambda runtime reports, with its kind
+// as the error type instead of the Go type name.
+func lambdaError(err error) error {
+	return messages.InvokeResponse_Error{
+		Type:    errorKind(err),
+		Message: err.Error(),
+	}
+}
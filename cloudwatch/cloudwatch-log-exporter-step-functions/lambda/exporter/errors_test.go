@@ -0,0 +1,50 @@
+package exporter
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aws/smithy-go"
+)
+
+func operationError(service, code, message string) error {
+	return &smithy.OperationError{
+		ServiceID:     service,
+		OperationName: "Operation",
+		Err:           &smithy.GenericAPIError{Code: code, Message: message},
+	}
+}
+
+func TestErrorKind(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"exporter error", newError(ErrInvalidConfig, "no regions"), ErrInvalidConfig},
+		{"wrapped exporter error", fmt.Errorf("listing: %w", newError(ErrNotFound, "gone")), ErrNotFound},
+		{"plain error", errors.New("boom"), ErrInternal},
+		{"throttled", operationError("CloudWatch Logs", "ThrottlingException", ""), ErrThrottled},
+		{"limit exceeded", operationError("CloudWatch Logs", "LimitExceededException", ""), ErrThrottled},
+		{"missing bucket", operationError("S3", "NoSuchBucket", ""), ErrDestinationUnavailable},
+		{"S3 access denied", operationError("S3", "AccessDenied", ""), ErrDestinationUnavailable},
+		{"STS access denied", operationError("STS", "AccessDenied", "not authorized to perform sts:AssumeRole"), ErrAccessDenied},
+		{"wrapped STS access denied", fmt.Errorf("error listing log groups: %w", operationError("STS", "AccessDenied", "")), ErrAccessDenied},
+		{"KMS error of S3", operationError("S3", "KMS.DisabledException", ""), ErrDestinationUnavailable},
+		{"bucket parameter", operationError("CloudWatch Logs", "InvalidParameterException", "The given bucket does not exist"), ErrDestinationUnavailable},
+		{"other parameter", operationError("CloudWatch Logs", "InvalidParameterException", "from must be before to"), ErrInvalidRequest},
+		{"access denied exception", operationError("CloudWatch Logs", "AccessDeniedException", ""), ErrAccessDenied},
+		{"missing parameter", operationError("SSM", "ParameterNotFound", ""), ErrInvalidConfig},
+		{"missing resource", operationError("CloudWatch Logs", "ResourceNotFoundException", ""), ErrNotFound},
+		{"condition failed", operationError("DynamoDB", "ConditionalCheckFailedException", ""), ErrConflict},
+		{"unknown code", operationError("DynamoDB", "InternalServerError", ""), ErrInternal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errorKind(tt.err); got != tt.want {
+				t.Errorf("errorKind() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+// Package exporter implements the actions of the CloudWatch log exporter. The
+// Lambda runs one action per invocation for the Step Functions state machine;
+// cmd/localrun runs the same sequence of actions in-process.
+package exporter
+
+import (
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+var (
+	dynamoClient  *dynamodb.Client
+	cwLogsClient  *cloudwatchlogs.Client
+	ssmClient     *ssm.Client
+	snsClient     *sns.Client
+	stsClient     *sts.Client
+	tableName     string
+	runsTableName string
+	ssmParamName  string
+	exportDays    int
+	snsTopic      string
+	leaseDuration time.Duration
+	memberRole    string
+	metricsOutput io.Writer
+)
+
+// Settings are the deployment's resources and defaults, which the stack passes
+// to the Lambda as environment variables.
+type Settings struct {
+	TableName       string
+	RunsTableName   string
+	ConfigParameter string
+	TopicArn        string
+	ExportDays      int
+	LeaseDuration   time.Duration
+	// MemberRoleName is the role the exporter may assume in member accounts.
+	MemberRoleName string
+	// Metrics receives the Embedded Metric Format lines; nil discards them.
+	Metrics io.Writer
+}
+
+// SettingsFromEnv reads the settings from the Lambda's environment.
+func SettingsFromEnv() Settings {
+	settings := Settings{
+		TableName:       os.Getenv("DYNAMODB_TABLE_NAME"),
+		RunsTableName:   os.Getenv("RUNS_TABLE_NAME"),
+		ConfigParameter: os.Getenv("SSM_PARAM_NAME"),
+		TopicArn:        os.Getenv("SNS_TOPIC_ARN"),
+		MemberRoleName:  os.Getenv("MEMBER_ACCOUNT_ROLE_NAME"),
+		Metrics:         os.Stdout,
+	}
+	settings.ExportDays, _ = strconv.Atoi(os.Getenv("EXPORT_DAYS"))
+	leaseMinutes, _ := strconv.Atoi(os.Getenv("LEASE_DURATION_MINUTES"))
+	settings.LeaseDuration = time.Duration(leaseMinutes) * time.Minute
+	return settings
+}
+
+// Init creates the AWS clients and applies the settings. It must be called
+// before HandleRequest.
+func Init(cfg aws.Config, settings Settings) {
+	dynamoClient = dynamodb.NewFromConfig(cfg)
+	cwLogsClient = cloudwatchlogs.NewFromConfig(cfg)
+	ssmClient = ssm.NewFromConfig(cfg)
+	snsClient = sns.NewFromConfig(cfg)
+	stsClient = sts.NewFromConfig(cfg)
+
+	tableName = settings.TableName
+	runsTableName = settings.RunsTableName
+	ssmParamName = settings.ConfigParameter
+	exportDays = settings.ExportDays
+	if exportDays == 0 {
+		exportDays = 1
+	}
+	snsTopic = settings.TopicArn
+	leaseDuration = settings.LeaseDuration
+	if leaseDuration == 0 {
+		leaseDuration = 360 * time.Minute
+	}
+	memberRole = settings.MemberRoleName
+	metricsOutput = settings.Metrics
+	if metricsOutput == nil {
+		metricsOutput = io.Discard
+	}
+}
@@ -0,0 +1,1014 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dynamodbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"prefix"
+)
+
+type Event struct {
+	Action       string            `json:"action"`
+	AccountId    string            `json:"accountId,omitempty"`
+	Owner        string            `json:"owner,omitempty"`
+	RunId        string            `json:"runId,omitempty"`
+	RunStartedAt string            `json:"runStartedAt,omitempty"`
+	LogGroupName string            `json:"logGroupName,omitempty"`
+	ItemName     string            `json:"itemName,omitempty"`
+	Region       string            `json:"region,omitempty"`
+	Status       string            `json:"status,omitempty"`
+	TaskId       string            `json:"taskId,omitempty"`
+	StartTime    int64             `json:"startTime,omitempty"`
+	EndTime      int64             `json:"endTime,omitempty"`
+	TopicArn     string            `json:"topicArn,omitempty"`
+	Message      string            `json:"message,omitempty"`
+	Severity     string            `json:"severity,omitempty"`
+	AllRegions   bool              `json:"allRegions,omitempty"`
+	StartDate    string            `json:"startDate,omitempty"`
+	EndDate      string            `json:"endDate,omitempty"`
+	Tags         map[string]string `json:"tags,omitempty"`
+	// Quiet keeps actions that report what they did, like cancelStaleTasks and reconcileLogGroups, from notifying.
+	Quiet bool `json:"quiet,omitempty"`
+}
+
+// itemKey returns the sort key of the DynamoDB item the event refers to. Scheduled
+// items are keyed by the log group name, backfill chunks carry their own item name.
+func (e Event) itemKey() string {
+	if e.ItemName != "" {
+		return e.ItemName
+	}
+	return e.LogGroupName
+}
+
+type LogGroup struct {
+	Region       string `json:"region"`
+	Name         string `json:"name"`
+	AccountId    string `json:"accountId"`
+	LogGroupName string `json:"logGroupName"`
+	Kind         string `json:"kind,omitempty"`
+	ItemStatus   string `json:"itemStatus"`
+	TaskId       string `json:"taskId,omitempty"`
+	// TaskStatus is the final status of TaskId once updateDynamoDB recorded it.
+	TaskStatus string    `json:"taskStatus,omitempty"`
+	StartTime  time.Time `json:"startTime,omitempty"`
+	EndTime    time.Time `json:"endTime,omitempty"`
+	// SelectionRule names the rule that included or excluded the log group.
+	SelectionRule string `json:"selectionRule,omitempty"`
+	// ExportedThrough is the end of the last COMPLETED export. The next export
+	// starts exactly here, so archives have neither gaps nor overlaps.
+	ExportedThrough time.Time `json:"exportedThrough,omitempty"`
+	// Attempts counts failed exports since the last success; a failed item is
+	// retried no earlier than NotBefore.
+	Attempts  int       `json:"attempts,omitempty"`
+	LastError string    `json:"lastError,omitempty"`
+	NotBefore time.Time `json:"notBefore,omitempty"`
+	// LeaseOwner is the execution that claimed an IN_PROGRESS item until LeaseExpiresAt.
+	LeaseOwner     string    `json:"leaseOwner,omitempty"`
+	LeaseExpiresAt time.Time `json:"leaseExpiresAt,omitempty"`
+	// BusySince is when the current claim first found its region busy.
+	BusySince time.Time `json:"busySince,omitempty"`
+	// WindowFrom and WindowTo pin the export range of backfill and chunk items.
+	WindowFrom time.Time `json:"windowFrom,omitempty"`
+	WindowTo   time.Time `json:"windowTo,omitempty"`
+	// PendingChunks names the chunk items a CHUNKED item waits for; ExportedThrough
+	// moves to ChunkedThrough once all of them completed.
+	PendingChunks  []string  `json:"pendingChunks,omitempty" dynamodbav:",stringset,omitempty"`
+	ChunkedThrough time.Time `json:"chunkedThrough,omitempty"`
+	// SkipReason tells why the last window was SKIPPED_EMPTY.
+	SkipReason string `json:"skipReason,omitempty"`
+	// Verification is OK or SUSPECT for the last export, see verifyExport.
+	Verification       string `json:"verification,omitempty"`
+	VerificationReason string `json:"verificationReason,omitempty"`
+	VerifiedObjects    int    `json:"verifiedObjects,omitempty"`
+	VerifiedBytes      int64  `json:"verifiedBytes,omitempty"`
+	ExpectedBytes      int64  `json:"expectedBytes,omitempty"`
+	VerifiedTaskId     string `json:"verifiedTaskId,omitempty"`
+	// DeletedAt is when reconcileLogGroups found the log group gone.
+	DeletedAt time.Time `json:"deletedAt,omitempty"`
+	// FirstSeenAt is when listLogGroups first found the log group.
+	FirstSeenAt time.Time `json:"firstSeenAt,omitempty"`
+}
+
+type RegionBucketMap struct {
+	Region string
+	Bucket string
+}
+
+type RegionTasks struct {
+	Region         string   `json:"region"`
+	AccountId      string   `json:"accountId"`
+	TasksRunning   bool     `json:"tasksRunning"`
+	RunningTaskIds []string `json:"runningTaskIds"`
+	PendingTaskIds []string `json:"pendingTaskIds"`
+	LogGroupNames  []string `json:"logGroupNames"`
+	Error          string   `json:"error,omitempty"`
+}
+
+// SuccessResult is the result of actions that only report whether they succeeded.
+type SuccessResult struct {
+	Success bool `json:"success"`
+}
+
+// PendingRegions is the input of the state machine's per-region Map state.
+type PendingRegions struct {
+	Regions []string `json:"regions"`
+}
+
+// ExportTaskResult describes the export task createExportTask started. TaskId is
+// empty when no task was needed: the log group is up to date, its window has no
+// events (SkipReason) or it was split into Chunks items. It is also empty when
+// the region is Busy with BlockingTaskId, which is retried after WaitSeconds, or
+// when the item waited too long for the region and was Deferred to a later run.
+type ExportTaskResult struct {
+	TaskId         string `json:"taskId,omitempty"`
+	Name           string `json:"name,omitempty"`
+	ItemName       string `json:"itemName,omitempty"`
+	AccountId      string `json:"accountId,omitempty"`
+	Region         string `json:"region,omitempty"`
+	SkipReason     string `json:"skipReason,omitempty"`
+	Chunks         int    `json:"chunks,omitempty"`
+	Busy           bool   `json:"busy,omitempty"`
+	BlockingTaskId string `json:"blockingTaskId,omitempty"`
+	WaitSeconds    int    `json:"waitSeconds,omitempty"`
+	Deferred       bool   `json:"deferred,omitempty"`
+}
+
+type ExportTaskStatus struct {
+	Status    *types.ExportTaskStatus `json:"status"`
+	StartTime *int64                  `json:"startTime"`
+	EndTime   *int64                  `json:"endTime"`
+}
+
+type UpdateResult struct {
+	Success    bool   `json:"success"`
+	ItemStatus string `json:"itemStatus"`
+	Attempts   int    `json:"attempts"`
+}
+
+type RunningTasks struct {
+	TasksRunning bool          `json:"tasksRunning"`
+	Regions      []RegionTasks `json:"regions"`
+	FreeRegions  []string      `json:"freeRegions"`
+}
+
+func HandleRequest(ctx context.Context, event Event) (interface{}, error) {
+	log.Printf("Received event: %+v", event)
+
+	started := time.Now()
+	result, err := handleAction(ctx, event)
+	putInvocationMetrics(event, started, err)
+	if err != nil {
+		return nil, lambdaError(err)
+	}
+	return result, nil
+}
+
+// handleAction runs the event's action. Each action returns its own result type.
+func handleAction(ctx context.Context, event Event) (interface{}, error) {
+	switch event.Action {
+	case "listLogGroups":
+		return listLogGroups(ctx, event)
+	case "checkRunningTasks":
+		if event.AllRegions || event.Region == "" {
+			return checkRunningTasksAllRegions(ctx)
+		}
+		return checkRunningTasks(ctx, event.AccountId, event.Region)
+	case "listPendingRegions":
+		return listPendingRegions(ctx)
+	case "getNextLogGroup":
+		return getNextLogGroup(ctx, event.Region, event.Owner)
+	case "createExportTask":
+		return createExportTask(ctx, event)
+	case "checkExportTaskStatus":
+		return checkExportTaskStatus(ctx, event)
+	case "updateDynamoDB":
+		return updateDynamoDB(ctx, event)
+	case "notifyFailure":
+		return notifyFailure(ctx, event)
+	case "sendNotification":
+		return sendNotification(ctx, event)
+	case "backfill":
+		return backfill(ctx, event)
+	case "validateConfig":
+		return validateConfig(ctx)
+	case "reapExpiredLeases":
+		return reapExpiredLeases(ctx, event)
+	case "cancelStaleTasks":
+		return cancelStaleTasks(ctx, event)
+	case "preflightBuckets":
+		return preflightBuckets(ctx, event)
+	case "planExport":
+		return planExport(ctx, event)
+	case "retryDeadLetters":
+		return retryDeadLetters(ctx, event)
+	case "getRunReport":
+		return getRunReport(ctx, event)
+	case "reconcileLogGroups":
+		return reconcileLogGroups(ctx, event)
+	case "writeManifest":
+		return writeManifest(ctx, event)
+	case "summarizeRun":
+		return summarizeRun(ctx, event)
+	default:
+		return nil, newError(ErrInvalidRequest, "unknown action: %s", event.Action)
+	}
+}
+
+// getLogGroupTags returns the tags of a log group, or no tags if they cannot be listed.
+func getLogGroupTags(ctx context.Context, cwLogsClient *cloudwatchlogs.Client, arn string) map[string]string {
+	tags, err := cwLogsClient.ListTagsForResource(ctx, &cloudwatchlogs.ListTagsForResourceInput{
+		ResourceArn: aws.String(arn),
+	})
+	if err != nil {
+		log.Printf("Error listing tags for log group %s: %v", arn, err)
+		// Continue processing even if tag listing fails
+		return map[string]string{}
+	}
+	return tags.Tags
+}
+
+// listLogGroups records every log group of the configured accounts and regions,
+// or of the event's region only, as an item.
+func listLogGroups(ctx context.Context, event Event) (SuccessResult, error) {
+	exporterConfig, err := getExporterConfig(ctx)
+	if err != nil {
+		return SuccessResult{}, err
+	}
+
+	now := time.Now()
+	for _, account := range exporterConfig.accounts(ctx) {
+		for _, rbm := range exporterConfig.Regions {
+			if event.Region != "" && rbm.Region != event.Region {
+				continue
+			}
+			regionConfig, _ := exporterConfig.regionConfig(rbm.Region)
+			cwLogsClient, err := newCWLogsClient(ctx, account, rbm.Region)
+			if err != nil {
+				log.Printf("Error loading config for account %s in region %s: %v", account.AccountId, rbm.Region, err)
+				continue
+			}
+
+			discovered, excluded := 0, 0
+			paginator := cloudwatchlogs.NewDescribeLogGroupsPaginator(cwLogsClient, &cloudwatchlogs.DescribeLogGroupsInput{})
+
+			for paginator.HasMorePages() {
+				page, err := paginator.NextPage(ctx)
+				if err != nil {
+					log.Printf("Error listing log groups of account %s in region %s: %v", account.AccountId, rbm.Region, err)
+					break
+				}
+
+				for _, logGroup := range page.LogGroups {
+					logGroupName := aws.ToString(logGroup.LogGroupName)
+					tags := getLogGroupTags(ctx, cwLogsClient, logGroupArn(account.AccountId, rbm.Region, logGroupName))
+
+					// Excluded log groups are recorded too, so the matched rule explains why they are not exported
+					selection := regionConfig.selectLogGroup(logGroup, tags, now)
+					itemStatus := "PENDING"
+					discovered++
+					if !selection.Selected {
+						itemStatus = "EXCLUDED"
+						excluded++
+					}
+
+					// Add log group to DynamoDB, keeping the watermark of existing items
+					_, err = dynamoClient.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+						TableName: aws.String(tableName),
+						Key: map[string]dynamodbtypes.AttributeValue{
+							"Region": &dynamodbtypes.AttributeValueMemberS{Value: rbm.Region},
+							"Name":   &dynamodbtypes.AttributeValueMemberS{Value: itemName(account.AccountId, logGroupName)},
+						},
+						UpdateExpression: aws.String("SET ItemStatus = :itemstatus, SelectionRule = :rule, AccountId = :account, LogGroupName = :loggroup, FirstSeenAt = if_not_exists(FirstSeenAt, :now) REMOVE DeletedAt, SkipReason"),
+						// Never requeue an item another execution holds a live lease on, nor a dead-lettered or chunked one
+						ConditionExpression: aws.String("attribute_not_exists(ItemStatus) OR (ItemStatus <> :deadletter AND ItemStatus <> :chunked AND (ItemStatus <> :inprogress OR LeaseExpiresAt < :now))"),
+						ExpressionAttributeValues: map[string]dynamodbtypes.AttributeValue{
+							":itemstatus": &dynamodbtypes.AttributeValueMemberS{Value: itemStatus},
+							":rule":       &dynamodbtypes.AttributeValueMemberS{Value: selection.Rule},
+							":account":    &dynamodbtypes.AttributeValueMemberS{Value: account.AccountId},
+							":loggroup":   &dynamodbtypes.AttributeValueMemberS{Value: logGroupName},
+							":inprogress": &dynamodbtypes.AttributeValueMemberS{Value: "IN_PROGRESS"},
+							":deadletter": &dynamodbtypes.AttributeValueMemberS{Value: "DEAD_LETTER"},
+							":chunked":    &dynamodbtypes.AttributeValueMemberS{Value: "CHUNKED"},
+							":now":        &dynamodbtypes.AttributeValueMemberS{Value: now.UTC().Format(time.RFC3339)},
+						},
+					})
+					var conditionFailed *dynamodbtypes.ConditionalCheckFailedException
+					if errors.As(err, &conditionFailed) {
+						log.Printf("Log group %s is dead-lettered, chunked or being exported by another execution", logGroupName)
+						continue
+					}
+					if err != nil {
+						log.Printf("Error writing log group to DynamoDB: %v", err)
+						continue
+					}
+
+					if account.AccountId == getAccountID(ctx) {
+						if err := migrateLegacyItem(ctx, rbm.Region, account.AccountId, logGroupName); err != nil {
+							log.Printf("Error migrating legacy item of log group %s: %v", logGroupName, err)
+						}
+					}
+				}
+			}
+			putMetrics(map[string]string{"Action": "listLogGroups", "Region": rbm.Region},
+				countMetric("LogGroupsDiscovered", discovered),
+				countMetric("LogGroupsExcluded", excluded),
+			)
+		}
+	}
+
+	return SuccessResult{Success: true}, nil
+}
+
+// migrateLegacyItem moves the watermark of an item keyed by the bare log group
+// name, as written before items carried the account ID, and deletes the old item.
+func migrateLegacyItem(ctx context.Context, region, accountID, logGroupName string) error {
+	legacy, err := getLogGroupItem(ctx, region, logGroupName)
+	if err != nil || legacy.Name == "" {
+		return err
+	}
+
+	if !legacy.ExportedThrough.IsZero() {
+		_, err = dynamoClient.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+			TableName: aws.String(tableName),
+			Key: map[string]dynamodbtypes.AttributeValue{
+				"Region": &dynamodbtypes.AttributeValueMemberS{Value: region},
+				"Name":   &dynamodbtypes.AttributeValueMemberS{Value: itemName(accountID, logGroupName)},
+			},
+			UpdateExpression: aws.String("SET ExportedThrough = if_not_exists(ExportedThrough, :watermark)"),
+			ExpressionAttributeValues: map[string]dynamodbtypes.AttributeValue{
+				":watermark": &dynamodbtypes.AttributeValueMemberS{Value: legacy.ExportedThrough.UTC().Format(time.RFC3339)},
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = dynamoClient.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]dynamodbtypes.AttributeValue{
+			"Region": &dynamodbtypes.AttributeValueMemberS{Value: region},
+			"Name":   &dynamodbtypes.AttributeValueMemberS{Value: logGroupName},
+		},
+	})
+	return err
+}
+
+func checkRunningTasks(ctx context.Context, accountID, region string) (RunningTasks, error) {
+	exporterConfig, err := getExporterConfig(ctx)
+	if err != nil {
+		return RunningTasks{}, err
+	}
+	account, err := exporterConfig.account(ctx, accountID)
+	if err != nil {
+		return RunningTasks{}, err
+	}
+
+	regionTasks, err := describeRegionTasks(ctx, account, region)
+	if err != nil {
+		return RunningTasks{}, err
+	}
+
+	result := RunningTasks{
+		TasksRunning: regionTasks.TasksRunning,
+		Regions:      []RegionTasks{regionTasks},
+		FreeRegions:  []string{},
+	}
+	if !regionTasks.TasksRunning {
+		result.FreeRegions = append(result.FreeRegions, region)
+	}
+	return result, nil
+}
+
+// checkRunningTasksAllRegions inspects every configured account and region
+// concurrently. A region is free only when no account has an active task in it;
+// a region that cannot be inspected is reported with its error and is never free.
+func checkRunningTasksAllRegions(ctx context.Context) (RunningTasks, error) {
+	exporterConfig, err := getExporterConfig(ctx)
+	if err != nil {
+		return RunningTasks{}, err
+	}
+
+	var regions []RegionTasks
+	for _, account := range exporterConfig.accounts(ctx) {
+		for _, rbm := range exporterConfig.Regions {
+			regions = append(regions, RegionTasks{AccountId: account.AccountId, Region: rbm.Region})
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := range regions {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			accountID, region := regions[i].AccountId, regions[i].Region
+			account, err := exporterConfig.account(ctx, accountID)
+			if err == nil {
+				regions[i], err = describeRegionTasks(ctx, account, region)
+			}
+			if err != nil {
+				log.Printf("Error checking running tasks of account %s in region %s: %v", accountID, region, err)
+				regions[i] = RegionTasks{AccountId: accountID, Region: region, Error: err.Error()}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	result := RunningTasks{
+		Regions:     regions,
+		FreeRegions: []string{},
+	}
+	busy := map[string]bool{}
+	for _, regionTasks := range regions {
+		if regionTasks.TasksRunning {
+			result.TasksRunning = true
+		}
+		if regionTasks.TasksRunning || regionTasks.Error != "" {
+			busy[regionTasks.Region] = true
+		}
+	}
+	for _, rbm := range exporterConfig.Regions {
+		if !busy[rbm.Region] {
+			result.FreeRegions = append(result.FreeRegions, rbm.Region)
+		}
+	}
+	return result, nil
+}
+
+func describeRegionTasks(ctx context.Context, account AccountConfig, region string) (RegionTasks, error) {
+	tasks, err := describeActiveExportTasks(ctx, account, region)
+	if err != nil {
+		return RegionTasks{}, err
+	}
+
+	regionTasks := RegionTasks{
+		Region:         region,
+		AccountId:      account.AccountId,
+		RunningTaskIds: []string{},
+		PendingTaskIds: []string{},
+		LogGroupNames:  []string{},
+	}
+	for _, task := range tasks {
+		switch task.Status.Code {
+		case types.ExportTaskStatusCodeRunning:
+			regionTasks.RunningTaskIds = append(regionTasks.RunningTaskIds, aws.ToString(task.TaskId))
+		case types.ExportTaskStatusCodePending:
+			regionTasks.PendingTaskIds = append(regionTasks.PendingTaskIds, aws.ToString(task.TaskId))
+		}
+		regionTasks.LogGroupNames = append(regionTasks.LogGroupNames, aws.ToString(task.LogGroupName))
+	}
+	regionTasks.TasksRunning = len(tasks) > 0
+	return regionTasks, nil
+}
+
+// describeActiveExportTasks returns the RUNNING and PENDING export tasks of an
+// account in a region.
+func describeActiveExportTasks(ctx context.Context, account AccountConfig, region string) ([]types.ExportTask, error) {
+	cwLogsClient, err := newCWLogsClient(ctx, account, region)
+	if err != nil {
+		return nil, err
+	}
+
+	var tasks []types.ExportTask
+	for _, statusCode := range []types.ExportTaskStatusCode{types.ExportTaskStatusCodeRunning, types.ExportTaskStatusCodePending} {
+		input := &cloudwatchlogs.DescribeExportTasksInput{StatusCode: statusCode}
+		for {
+			output, err := cwLogsClient.DescribeExportTasks(ctx, input)
+			if err != nil {
+				return nil, fmt.Errorf("error describing export tasks in region %s: %w", region, err)
+			}
+			for _, task := range output.ExportTasks {
+				if task.Status != nil {
+					tasks = append(tasks, task)
+				}
+			}
+			if output.NextToken == nil {
+				break
+			}
+			input.NextToken = output.NextToken
+		}
+	}
+	return tasks, nil
+}
+
+// getNextLogGroup claims the next PENDING item for owner. When a region is given
+// only that region's items are considered, so each region can be drained by its
+// own lane. Items claimed concurrently by another execution are skipped.
+func getNextLogGroup(ctx context.Context, region, owner string) (*LogGroup, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(tableName),
+		IndexName:              aws.String("ItemStatusIndex"), // Add a GSI for ItemStatus
+		KeyConditionExpression: aws.String("ItemStatus = :status"),
+		// Items waiting out a retry delay are not due yet
+		FilterExpression: aws.String("attribute_not_exists(NotBefore) OR NotBefore <= :now"),
+		ExpressionAttributeValues: map[string]dynamodbtypes.AttributeValue{
+			":status": &dynamodbtypes.AttributeValueMemberS{Value: "PENDING"},
+			":now":    &dynamodbtypes.AttributeValueMemberS{Value: time.Now().UTC().Format(time.RFC3339)},
+		},
+		Limit: aws.Int32(10),
+	}
+	if region != "" {
+		input.IndexName = aws.String("RegionStatusIndex")
+		input.KeyConditionExpression = aws.String("#region = :region AND ItemStatus = :status")
+		input.ExpressionAttributeNames = map[string]string{"#region": "Region"}
+		input.ExpressionAttributeValues[":region"] = &dynamodbtypes.AttributeValueMemberS{Value: region}
+	}
+	if owner == "" {
+		owner = "anonymous"
+	}
+
+	now := time.Now()
+	paginator := dynamodb.NewQueryPaginator(dynamoClient, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error querying DynamoDB: %w", err)
+		}
+
+		var candidates []LogGroup
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &candidates); err != nil {
+			return nil, fmt.Errorf("error unmarshalling DynamoDB item: %w", err)
+		}
+
+		for _, candidate := range candidates {
+			logGroup, claimed, err := claimLogGroup(ctx, candidate.Region, candidate.Name, owner, now)
+			if err != nil {
+				return nil, err
+			}
+			if !claimed {
+				log.Printf("Log group %s was claimed by another execution", candidate.Name)
+				continue
+			}
+
+			if logGroup.LogGroupName == "" {
+				logGroup.LogGroupName = logGroup.Name
+			}
+			if logGroup.AccountId == "" {
+				logGroup.AccountId = getAccountID(ctx)
+			}
+			return &logGroup, nil
+		}
+	}
+
+	// No item is returned as null, which the state machine checks for
+	return nil, nil
+}
+
+// listPendingRegions returns the configured regions that still have PENDING items,
+// which the state machine uses as the input of its per-region Map state.
+func listPendingRegions(ctx context.Context) (PendingRegions, error) {
+	regionBucketMap, err := getRegionBucketMap(ctx)
+	if err != nil {
+		return PendingRegions{}, err
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	regions, configured := []string{}, []string{}
+	for _, rbm := range regionBucketMap {
+		configured = append(configured, rbm.Region)
+		paginator := dynamodb.NewQueryPaginator(dynamoClient, &dynamodb.QueryInput{
+			TableName:              aws.String(tableName),
+			IndexName:              aws.String("RegionStatusIndex"),
+			KeyConditionExpression: aws.String("#region = :region AND ItemStatus = :status"),
+			FilterExpression:       aws.String("attribute_not_exists(NotBefore) OR NotBefore <= :now"),
+			ExpressionAttributeNames: map[string]string{
+				"#region": "Region",
+			},
+			ExpressionAttributeValues: map[string]dynamodbtypes.AttributeValue{
+				":region": &dynamodbtypes.AttributeValueMemberS{Value: rbm.Region},
+				":status": &dynamodbtypes.AttributeValueMemberS{Value: "PENDING"},
+				":now":    &dynamodbtypes.AttributeValueMemberS{Value: now},
+			},
+			Select: dynamodbtypes.SelectCount,
+		})
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return PendingRegions{}, fmt.Errorf("error querying pending items for region %s: %w", rbm.Region, err)
+			}
+			if page.Count > 0 {
+				regions = append(regions, rbm.Region)
+				break
+			}
+		}
+	}
+	// Runs start here, so the depth is reported once per run
+	putQueueDepthMetrics(ctx, configured)
+
+	return PendingRegions{Regions: regions}, nil
+}
+
+func createExportTask(ctx context.Context, event Event) (ExportTaskResult, error) {
+	if event.LogGroupName == "" {
+		logGroup, err := getNextLogGroup(ctx, event.Region, event.Owner)
+		if err != nil {
+			return ExportTaskResult{}, err
+		}
+		if logGroup == nil {
+			log.Printf("No pending log group left in region: %s", event.Region)
+			return ExportTaskResult{}, nil
+		}
+		event.LogGroupName = logGroup.LogGroupName
+		event.ItemName = logGroup.Name
+		event.AccountId = logGroup.AccountId
+		event.Region = logGroup.Region
+	}
+
+	log.Printf("Starting createExportTask for log group: %s of account %s in region: %s", event.LogGroupName, event.AccountId, event.Region)
+
+	exporterConfig, err := getExporterConfig(ctx)
+	if err != nil {
+		log.Printf("Error getting exporter config: %v", err)
+		return ExportTaskResult{}, fmt.Errorf("failed to get exporter config: %w", err)
+	}
+
+	regionConfig, ok := exporterConfig.regionConfig(event.Region)
+	if !ok {
+		log.Printf("No destination bucket found for region: %s", event.Region)
+		return ExportTaskResult{}, newError(ErrInvalidConfig, "no destination bucket found for region %s", event.Region)
+	}
+	bucketName := regionConfig.Bucket
+	log.Printf("Destination bucket for region %s: %s", event.Region, bucketName)
+
+	account, err := exporterConfig.account(ctx, event.AccountId)
+	if err != nil {
+		return ExportTaskResult{}, err
+	}
+
+	cwLogsClient, err := newCWLogsClient(ctx, account, event.Region)
+	if err != nil {
+		log.Printf("Error loading AWS config for region %s: %v", event.Region, err)
+		return ExportTaskResult{}, err
+	}
+
+	item, err := getLogGroupItem(ctx, event.Region, event.itemKey())
+	if err != nil {
+		return ExportTaskResult{}, err
+	}
+
+	result := ExportTaskResult{
+		Name:      event.LogGroupName,
+		ItemName:  event.itemKey(),
+		AccountId: account.AccountId,
+		Region:    event.Region,
+	}
+
+	from, to := item.WindowFrom, item.WindowTo
+	if from.IsZero() || to.IsZero() {
+		from, to = exportWindow(item.ExportedThrough, regionConfig.ExportDays, time.Now().UTC())
+	}
+	if !from.Before(to) {
+		log.Printf("Log group %s is already exported through %s", event.LogGroupName, item.ExportedThrough.Format(time.RFC3339))
+		if err := setItemStatus(ctx, event.Region, event.itemKey(), "UP_TO_DATE"); err != nil {
+			return ExportTaskResult{}, err
+		}
+		return result, nil
+	}
+
+	// Export tasks of windows without events only cost a Step Functions round trip
+	now := time.Now().UTC()
+	group, err := describeLogGroup(ctx, cwLogsClient, event.LogGroupName)
+	if err != nil {
+		log.Printf("Could not describe log group, exporting it unchunked: %v", err)
+	}
+	reason, through, err := emptyWindowReason(ctx, cwLogsClient, group, from, to, now)
+	if err != nil {
+		log.Printf("Could not tell whether the export window is empty, exporting it: %v", err)
+	} else if reason != "" && (item.WindowFrom.IsZero() || !through.Before(to)) {
+		if err := skipEmptyWindow(ctx, item, reason, through); err != nil {
+			return ExportTaskResult{}, err
+		}
+		// An empty chunk counts as done for its parent
+		if err := completeChunk(ctx, event.Region, event.itemKey()); err != nil {
+			return ExportTaskResult{}, err
+		}
+		result.SkipReason = reason
+		return result, nil
+	}
+
+	// Only scheduled items are split; their chunks carry a fixed window
+	if item.Kind == "" {
+		if chunks := chunkCount(regionConfig, group, from, to, now); chunks > 1 {
+			ranges := splitWindow(from, to, chunks)
+			if err := chunkLogGroup(ctx, item, ranges, event.Owner); err != nil {
+				return ExportTaskResult{}, err
+			}
+			result.Chunks = len(ranges)
+			return result, nil
+		}
+	}
+
+	log.Printf("Exporting logs from %s to %s", from.Format(time.RFC3339), to.Format(time.RFC3339))
+
+	// Objects are laid out by the first day of the exported range
+	destinationPrefix := prefix.Expand(regionConfig.PrefixTemplate, prefix.Fields{
+		Account:  account.AccountId,
+		Region:   event.Region,
+		LogGroup: event.LogGroupName,
+		RunID:    event.RunId,
+		Time:     from,
+	})
+	log.Printf("Destination prefix: %s", destinationPrefix)
+
+	input := &cloudwatchlogs.CreateExportTaskInput{
+		Destination:       aws.String(bucketName),
+		LogGroupName:      aws.String(event.LogGroupName),
+		From:              aws.Int64(from.UnixNano() / 1000000),
+		To:                aws.Int64(to.UnixNano() / 1000000),
+		DestinationPrefix: aws.String(destinationPrefix),
+	}
+
+	log.Printf("CreateExportTask input: Destination=%s, LogGroupName=%s, From=%s, To=%s, DestinationPrefix=%s",
+		*input.Destination,
+		*input.LogGroupName,
+		time.Unix(0, *input.From*int64(time.Millisecond)).Format(time.RFC3339),
+		time.Unix(0, *input.To*int64(time.Millisecond)).Format(time.RFC3339),
+		*input.DestinationPrefix)
+
+	output, err := cwLogsClient.CreateExportTask(ctx, input)
+	// Only one export task per account can be active in a region
+	var limitExceeded *types.LimitExceededException
+	if errors.As(err, &limitExceeded) {
+		return regionBusy(ctx, account, item, event, result, time.Now())
+	}
+	if err != nil {
+		log.Printf("Error creating export task: %v", err)
+		return ExportTaskResult{}, fmt.Errorf("error creating export task: %w", err)
+	}
+
+	log.Printf("Export task created successfully. Task ID: %s", *output.TaskId)
+	putMetrics(map[string]string{"Action": "createExportTask", "Region": event.Region}, countMetric("ExportTasksCreated", 1))
+
+	if err := recordItemTask(ctx, event.Region, event.itemKey(), event.Owner, *output.TaskId, taskLeaseExpiry(regionConfig, time.Now())); err != nil {
+		// The task still completes normally, it can only not be cancelled when stale
+		log.Printf("Error recording export task on the item: %v", err)
+	}
+
+	err = recordRunExport(ctx, RunExport{
+		RunId:        event.RunId,
+		TaskId:       *output.TaskId,
+		Region:       event.Region,
+		AccountId:    account.AccountId,
+		LogGroupName: event.LogGroupName,
+		ItemName:     event.itemKey(),
+		From:         from,
+		To:           to,
+		ExportStatus: "RUNNING",
+		StartedAt:    time.Now().UTC(),
+	})
+	if err != nil {
+		// The export task is running already, so only its history entry is lost
+		log.Printf("Error recording run history: %v", err)
+	}
+
+	result.TaskId = *output.TaskId
+	return result, nil
+}
+
+// exportWindow returns the range to export: it starts at the watermark (or
+// days back for a log group never exported) and ends at the last complete UTC
+// day boundary.
+func exportWindow(exportedThrough time.Time, days int, now time.Time) (time.Time, time.Time) {
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if exportedThrough.IsZero() {
+		return to.AddDate(0, 0, -days), to
+	}
+	return exportedThrough.UTC(), to
+}
+
+func getLogGroupItem(ctx context.Context, region, name string) (LogGroup, error) {
+	output, err := dynamoClient.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]dynamodbtypes.AttributeValue{
+			"Region": &dynamodbtypes.AttributeValueMemberS{Value: region},
+			"Name":   &dynamodbtypes.AttributeValueMemberS{Value: name},
+		},
+	})
+	if err != nil {
+		return LogGroup{}, fmt.Errorf("error reading DynamoDB item for log group %s: %w", name, err)
+	}
+
+	var logGroup LogGroup
+	if err := attributevalue.UnmarshalMap(output.Item, &logGroup); err != nil {
+		return LogGroup{}, fmt.Errorf("error unmarshalling DynamoDB item: %w", err)
+	}
+	return logGroup, nil
+}
+
+func setItemStatus(ctx context.Context, region, name, status string) error {
+	_, err := dynamoClient.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]dynamodbtypes.AttributeValue{
+			"Region": &dynamodbtypes.AttributeValueMemberS{Value: region},
+			"Name":   &dynamodbtypes.AttributeValueMemberS{Value: name},
+		},
+		UpdateExpression: aws.String("SET ItemStatus = :itemstatus REMOVE LeaseOwner, LeaseExpiresAt"),
+		ExpressionAttributeValues: map[string]dynamodbtypes.AttributeValue{
+			":itemstatus": &dynamodbtypes.AttributeValueMemberS{Value: status},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("error updating status of log group %s: %w", name, err)
+	}
+	return nil
+}
+
+func checkExportTaskStatus(ctx context.Context, event Event) (ExportTaskStatus, error) {
+	cwLogsClient, err := cwLogsClientFor(ctx, event.AccountId, event.Region)
+	if err != nil {
+		return ExportTaskStatus{}, err
+	}
+	input := &cloudwatchlogs.DescribeExportTasksInput{
+		TaskId: aws.String(event.TaskId),
+	}
+
+	output, err := cwLogsClient.DescribeExportTasks(ctx, input)
+	if err != nil {
+		return ExportTaskStatus{}, fmt.Errorf("error describing export task: %w", err)
+	}
+
+	if len(output.ExportTasks) == 0 {
+		return ExportTaskStatus{}, newError(ErrNotFound, "export task %s not found", event.TaskId)
+	}
+
+	task := output.ExportTasks[0]
+	return ExportTaskStatus{
+		Status:    task.Status,
+		StartTime: task.From,
+		EndTime:   task.To,
+	}, nil
+}
+
+func updateDynamoDB(ctx context.Context, event Event) (UpdateResult, error) {
+	// A task cancelled by cancelStaleTasks is recorded already when its lane
+	// reports it, and must not count as a second attempt
+	item, err := getLogGroupItem(ctx, event.Region, event.itemKey())
+	if err != nil {
+		return UpdateResult{}, err
+	}
+	if event.TaskId != "" && item.TaskId == event.TaskId && item.TaskStatus != "" {
+		log.Printf("Export task %s of %s is recorded already", event.TaskId, event.itemKey())
+		if err := finishRunExport(ctx, event.RunId, event.TaskId, event.Status, item.ItemStatus, event.Message, time.Now()); err != nil {
+			log.Printf("Error recording run history: %v", err)
+		}
+		return UpdateResult{Success: true, ItemStatus: item.ItemStatus, Attempts: item.Attempts}, nil
+	}
+
+	startTime := time.Unix(0, event.StartTime*int64(time.Millisecond)).UTC()
+	endTime := time.Unix(0, event.EndTime*int64(time.Millisecond)).UTC()
+
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]dynamodbtypes.AttributeValue{
+			"Region": &dynamodbtypes.AttributeValueMemberS{Value: event.Region},
+			"Name":   &dynamodbtypes.AttributeValueMemberS{Value: event.itemKey()},
+		},
+		UpdateExpression: aws.String("SET #itemStatus = :itemstatus, #taskId = :taskid, #taskStatus = :taskstatus, #startTime = :starttime, #endTime = :endtime"),
+		ExpressionAttributeNames: map[string]string{
+			"#itemStatus": "ItemStatus",
+			"#taskId":     "TaskId",
+			"#taskStatus": "TaskStatus",
+			"#startTime":  "StartTime",
+			"#endTime":    "EndTime",
+		},
+		ExpressionAttributeValues: map[string]dynamodbtypes.AttributeValue{
+			":itemstatus": &dynamodbtypes.AttributeValueMemberS{Value: event.Status},
+			":taskid":     &dynamodbtypes.AttributeValueMemberS{Value: event.TaskId},
+			":taskstatus": &dynamodbtypes.AttributeValueMemberS{Value: event.Status},
+			":starttime":  &dynamodbtypes.AttributeValueMemberS{Value: startTime.Format(time.RFC3339)},
+			":endtime":    &dynamodbtypes.AttributeValueMemberS{Value: endTime.Format(time.RFC3339)},
+		},
+	}
+
+	outcome := exportOutcome(event.Status, event.TaskId, item)
+	if outcome == "SUSPECT" {
+		event.Message = "export is SUSPECT: " + item.VerificationReason
+	}
+
+	// Release the lease taken by getNextLogGroup
+	remove := []string{"LeaseOwner", "LeaseExpiresAt"}
+	itemStatus, attempts := event.Status, 0
+	switch outcome {
+	case string(types.ExportTaskStatusCodeCompleted):
+		// Only a COMPLETED export moves the watermark; failed ranges are retried
+		*input.UpdateExpression += ", #exportedThrough = :endtime, #attempts = :attempts"
+		input.ExpressionAttributeNames["#exportedThrough"] = "ExportedThrough"
+		input.ExpressionAttributeNames["#attempts"] = "Attempts"
+		input.ExpressionAttributeValues[":attempts"] = &dynamodbtypes.AttributeValueMemberN{Value: "0"}
+		remove = append(remove, "LastError", "NotBefore")
+	case string(types.ExportTaskStatusCodeFailed), string(types.ExportTaskStatusCodeCancelled), string(types.ExportTaskStatusCodePendingCancel), "SUSPECT":
+		var err error
+		itemStatus, attempts, err = recordFailure(ctx, input, event, time.Now())
+		if err != nil {
+			return UpdateResult{}, err
+		}
+	}
+	*input.UpdateExpression += " REMOVE " + strings.Join(remove, ", ")
+	leaseCondition(input, event.Owner)
+
+	_, err = dynamoClient.UpdateItem(ctx, input)
+	if err != nil {
+		return UpdateResult{}, fmt.Errorf("error updating DynamoDB: %w", err)
+	}
+
+	switch {
+	case outcome == string(types.ExportTaskStatusCodeCompleted):
+		if err := completeChunk(ctx, event.Region, event.itemKey()); err != nil {
+			return UpdateResult{}, err
+		}
+	case itemStatus == "DEAD_LETTER":
+		parentName, deadLettered, err := deadLetterChunkParent(ctx, event.Region, event.itemKey(), failureMessage(event))
+		if err != nil {
+			return UpdateResult{}, err
+		}
+		if deadLettered {
+			err := notify(ctx, "", Notification{
+				Severity: severityError,
+				Subject:  "CloudWatch log export dead-lettered",
+				Message:  fmt.Sprintf("Chunk %s of log group %s in region %s was dead-lettered after %d attempts, so the export of %s stopped. Run retryDeadLetters once the cause is fixed.", event.itemKey(), event.LogGroupName, event.Region, attempts, parentName),
+			})
+			if err != nil {
+				log.Printf("Error notifying dead-lettered log group %s: %v", parentName, err)
+			}
+		}
+	}
+
+	switch outcome {
+	case string(types.ExportTaskStatusCodeCompleted):
+		putMetrics(map[string]string{"Action": "updateDynamoDB", "Region": event.Region}, countMetric("ExportTasksCompleted", 1))
+	case string(types.ExportTaskStatusCodeFailed), string(types.ExportTaskStatusCodeCancelled), string(types.ExportTaskStatusCodePendingCancel), "SUSPECT":
+		putMetrics(map[string]string{"Action": "updateDynamoDB", "Region": event.Region}, countMetric("ExportTasksFailed", 1))
+	}
+
+	runError := ""
+	if itemStatus != string(types.ExportTaskStatusCodeCompleted) {
+		runError = event.Message
+	}
+	if err := finishRunExport(ctx, event.RunId, event.TaskId, event.Status, itemStatus, runError, time.Now()); err != nil {
+		log.Printf("Error recording run history: %v", err)
+	}
+
+	return UpdateResult{
+		Success:    true,
+		ItemStatus: itemStatus,
+		Attempts:   attempts,
+	}, nil
+}
+
+// exportOutcome is the final status of task taskID, except that a COMPLETED
+// task whose export verified SUSPECT is retried like a failed one, so the
+// watermark stays before the window.
+func exportOutcome(status, taskID string, item LogGroup) string {
+	if status == string(types.ExportTaskStatusCodeCompleted) && item.VerifiedTaskId == taskID && item.Verification == "SUSPECT" {
+		return "SUSPECT"
+	}
+	return status
+}
+
+func notifyFailure(ctx context.Context, event Event) (SuccessResult, error) {
+	startTime := time.Unix(0, event.StartTime*int64(time.Millisecond))
+	message := fmt.Sprintf("Export task failed for log group %s in region %s. Task ID: %s, Status: %s, Start Time: %s",
+		event.LogGroupName, event.Region, event.TaskId, event.Status, startTime.Format(time.RFC3339))
+
+	err := notify(ctx, "", Notification{
+		Severity: severityError,
+		Subject:  "CloudWatch log export failed",
+		Message:  message,
+	})
+	if err != nil {
+		return SuccessResult{}, err
+	}
+
+	return SuccessResult{Success: true}, nil
+}
+
+// sendNotification publishes the message of the event to the targets routed for
+// its severity, ERROR unless given.
+func sendNotification(ctx context.Context, event Event) (SuccessResult, error) {
+	severity := event.Severity
+	if severity == "" {
+		severity = severityError
+	}
+
+	err := notify(ctx, event.TopicArn, Notification{
+		Severity: severity,
+		Message:  event.Message,
+	})
+	if err != nil {
+		return SuccessResult{}, err
+	}
+
+	return SuccessResult{Success: true}, nil
+}
@@ -0,0 +1,64 @@
+package exporter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExportWindow(t *testing.T) {
+	now := time.Date(2026, 10, 18, 0, 5, 0, 0, time.UTC)
+	midnight := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name            string
+		exportedThrough time.Time
+		days            int
+		now             time.Time
+		from, to        time.Time
+	}{
+		{"never exported", time.Time{}, 1, now, midnight.AddDate(0, 0, -1), midnight},
+		{"never exported, several days", time.Time{}, 3, now, midnight.AddDate(0, 0, -3), midnight},
+		{"from the watermark", midnight.AddDate(0, 0, -5), 1, now, midnight.AddDate(0, 0, -5), midnight},
+		{"watermark within a day", midnight.Add(-90 * time.Minute), 1, now, midnight.Add(-90 * time.Minute), midnight},
+		{"watermark in another zone", midnight.AddDate(0, 0, -1).In(time.FixedZone("UTC+2", 2*60*60)), 1, now, midnight.AddDate(0, 0, -1), midnight},
+		{"up to date", midnight, 1, now, midnight, midnight},
+		{"late in the day", time.Time{}, 1, midnight.Add(23*time.Hour + 59*time.Minute), midnight.AddDate(0, 0, -1), midnight},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, to := exportWindow(tt.exportedThrough, tt.days, tt.now)
+			if !from.Equal(tt.from) || !to.Equal(tt.to) {
+				t.Errorf("exportWindow() = %s, %s, want %s, %s", from, to, tt.from, tt.to)
+			}
+			if from.Location() != time.UTC {
+				t.Errorf("exportWindow() from is in %s, want UTC", from.Location())
+			}
+		})
+	}
+}
+
+func TestExportOutcome(t *testing.T) {
+	now := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+	quiet := verifyExport(nil, now.AddDate(0, 0, -1), now, 0, 0, now)
+	suspect := Verification{Status: "SUSPECT", Reason: "export wrote no objects, expected about 1073741824 bytes"}
+
+	tests := []struct {
+		name   string
+		status string
+		item   LogGroup
+		want   string
+	}{
+		{"completed", "COMPLETED", LogGroup{}, "COMPLETED"},
+		{"quiet window without objects", "COMPLETED", LogGroup{VerifiedTaskId: "task-1", Verification: quiet.Status}, "COMPLETED"},
+		{"suspect", "COMPLETED", LogGroup{VerifiedTaskId: "task-1", Verification: suspect.Status}, "SUSPECT"},
+		{"suspect verification of an earlier task", "COMPLETED", LogGroup{VerifiedTaskId: "task-0", Verification: suspect.Status}, "COMPLETED"},
+		{"failed", "FAILED", LogGroup{VerifiedTaskId: "task-1", Verification: suspect.Status}, "FAILED"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exportOutcome(tt.status, "task-1", tt.item); got != tt.want {
+				t.Errorf("exportOutcome() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dynamodbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// claimLogGroup moves a PENDING item to IN_PROGRESS under a lease held by owner.
+// It returns false without an error when another execution claimed it first.
+func claimLogGroup(ctx context.Context, region, name, owner string, now time.Time) (LogGroup, bool, error) {
+	output, err := dynamoClient.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]dynamodbtypes.AttributeValue{
+			"Region": &dynamodbtypes.AttributeValueMemberS{Value: region},
+			"Name":   &dynamodbtypes.AttributeValueMemberS{Value: name},
+		},
+		UpdateExpression:    aws.String("SET ItemStatus = :inprogress, LeaseOwner = :owner, LeaseExpiresAt = :expiry REMOVE BusySince"),
+		ConditionExpression: aws.String("ItemStatus = :pending"),
+		ExpressionAttributeValues: map[string]dynamodbtypes.AttributeValue{
+			":inprogress": &dynamodbtypes.AttributeValueMemberS{Value: "IN_PROGRESS"},
+			":pending":    &dynamodbtypes.AttributeValueMemberS{Value: "PENDING"},
+			":owner":      &dynamodbtypes.AttributeValueMemberS{Value: owner},
+			":expiry":     &dynamodbtypes.AttributeValueMemberS{Value: now.Add(leaseDuration).UTC().Format(time.RFC3339)},
+		},
+		ReturnValues: dynamodbtypes.ReturnValueAllNew,
+	})
+	if err != nil {
+		var conditionFailed *dynamodbtypes.ConditionalCheckFailedException
+		if errors.As(err, &conditionFailed) {
+			return LogGroup{}, false, nil
+		}
+		return LogGroup{}, false, fmt.Errorf("error claiming log group %s: %w", name, err)
+	}
+
+	var logGroup LogGroup
+	if err := attributevalue.UnmarshalMap(output.Attributes, &logGroup); err != nil {
+		return LogGroup{}, false, fmt.Errorf("error unmarshalling DynamoDB item: %w", err)
+	}
+	return logGroup, true, nil
+}
+
+// leaseCondition guards writes of an execution against items whose lease has
+// since been reaped and claimed by another execution.
+func leaseCondition(input *dynamodb.UpdateItemInput, owner string) {
+	if owner == "" {
+		return
+	}
+	input.ConditionExpression = aws.String("attribute_not_exists(LeaseOwner) OR LeaseOwner = :owner")
+	input.ExpressionAttributeValues[":owner"] = &dynamodbtypes.AttributeValueMemberS{Value: owner}
+}
+
+type ReapResult struct {
+	Reaped []string `json:"reaped"`
+}
+
+// reapExpiredLeases returns IN_PROGRESS items whose lease expired, e.g. because
+// their execution was aborted, to PENDING. The event's region narrows it down.
+func reapExpiredLeases(ctx context.Context, event Event) (ReapResult, error) {
+	now := time.Now().UTC().Format(time.RFC3339)
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(tableName),
+		IndexName:              aws.String("ItemStatusIndex"),
+		KeyConditionExpression: aws.String("ItemStatus = :inprogress"),
+		FilterExpression:       aws.String("attribute_not_exists(LeaseExpiresAt) OR LeaseExpiresAt < :now"),
+		ExpressionAttributeValues: map[string]dynamodbtypes.AttributeValue{
+			":inprogress": &dynamodbtypes.AttributeValueMemberS{Value: "IN_PROGRESS"},
+			":now":        &dynamodbtypes.AttributeValueMemberS{Value: now},
+		},
+	}
+	if event.Region != "" {
+		input.IndexName = aws.String("RegionStatusIndex")
+		input.KeyConditionExpression = aws.String("#region = :region AND ItemStatus = :inprogress")
+		input.ExpressionAttributeNames = map[string]string{"#region": "Region"}
+		input.ExpressionAttributeValues[":region"] = &dynamodbtypes.AttributeValueMemberS{Value: event.Region}
+	}
+
+	reaped := []string{}
+	paginator := dynamodb.NewQueryPaginator(dynamoClient, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return ReapResult{}, fmt.Errorf("error querying expired leases: %w", err)
+		}
+
+		var logGroups []LogGroup
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &logGroups); err != nil {
+			return ReapResult{}, fmt.Errorf("error unmarshalling DynamoDB items: %w", err)
+		}
+
+		for _, logGroup := range logGroups {
+			_, err := dynamoClient.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+				TableName: aws.String(tableName),
+				Key: map[string]dynamodbtypes.AttributeValue{
+					"Region": &dynamodbtypes.AttributeValueMemberS{Value: logGroup.Region},
+					"Name":   &dynamodbtypes.AttributeValueMemberS{Value: logGroup.Name},
+				},
+				UpdateExpression:    aws.String("SET ItemStatus = :pending REMOVE LeaseOwner, LeaseExpiresAt"),
+				ConditionExpression: aws.String("ItemStatus = :inprogress AND (attribute_not_exists(LeaseExpiresAt) OR LeaseExpiresAt < :now)"),
+				ExpressionAttributeValues: map[string]dynamodbtypes.AttributeValue{
+					":pending":    &dynamodbtypes.AttributeValueMemberS{Value: "PENDING"},
+					":inprogress": &dynamodbtypes.AttributeValueMemberS{Value: "IN_PROGRESS"},
+					":now":        &dynamodbtypes.AttributeValueMemberS{Value: now},
+				},
+			})
+			if err != nil {
+				var conditionFailed *dynamodbtypes.ConditionalCheckFailedException
+				if errors.As(err, &conditionFailed) {
+					continue
+				}
+				return ReapResult{}, fmt.Errorf("error reaping lease of log group %s: %w", logGroup.Name, err)
+			}
+			log.Printf("Reaped expired lease of %s in region %s held by %s", logGroup.Name, logGroup.Region, logGroup.LeaseOwner)
+			reaped = append(reaped, logGroup.Region+"/"+logGroup.Name)
+		}
+	}
+
+	return ReapResult{Reaped: reaped}, nil
+}
@@ -0,0 +1,160 @@
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+const manifestName = "_manifest.json"
+
+// Manifest lists what a COMPLETED export task wrote. Its presence marks the
+// task's objects as complete for downstream jobs.
+type Manifest struct {
+	TaskId       string           `json:"taskId"`
+	AccountId    string           `json:"accountId"`
+	Region       string           `json:"region"`
+	LogGroupName string           `json:"logGroupName"`
+	From         time.Time        `json:"from"`
+	To           time.Time        `json:"to"`
+	Bucket       string           `json:"bucket"`
+	Prefix       string           `json:"prefix"`
+	Objects      []ManifestObject `json:"objects"`
+	TotalBytes   int64            `json:"totalBytes"`
+	Verification Verification     `json:"verification"`
+	CreatedAt    time.Time        `json:"createdAt"`
+}
+
+type ManifestObject struct {
+	Key  string `json:"key"`
+	Size int64  `json:"size"`
+}
+
+type ManifestResult struct {
+	ManifestKey        string `json:"manifestKey"`
+	Objects            int    `json:"objects"`
+	TotalBytes         int64  `json:"totalBytes"`
+	Verification       string `json:"verification"`
+	VerificationReason string `json:"verificationReason"`
+}
+
+// writeManifest lists the objects of an export task, which CloudWatch Logs
+// writes below <destination prefix>/<task ID>/, verifies them and stores a
+// manifest next to them. The verification is also recorded on the item.
+func writeManifest(ctx context.Context, event Event) (ManifestResult, error) {
+	cwLogsClient, err := cwLogsClientFor(ctx, event.AccountId, event.Region)
+	if err != nil {
+		return ManifestResult{}, err
+	}
+	output, err := cwLogsClient.DescribeExportTasks(ctx, &cloudwatchlogs.DescribeExportTasksInput{
+		TaskId: aws.String(event.TaskId),
+	})
+	if err != nil {
+		return ManifestResult{}, fmt.Errorf("error describing export task: %w", err)
+	}
+	if len(output.ExportTasks) == 0 {
+		return ManifestResult{}, newError(ErrNotFound, "export task %s not found", event.TaskId)
+	}
+	task := output.ExportTasks[0]
+
+	exporterConfig, err := getExporterConfig(ctx)
+	if err != nil {
+		return ManifestResult{}, err
+	}
+	regionConfig, _ := exporterConfig.regionConfig(event.Region)
+
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(event.Region))
+	if err != nil {
+		return ManifestResult{}, fmt.Errorf("error loading config for region %s: %w", event.Region, err)
+	}
+	s3Client := s3.NewFromConfig(cfg)
+
+	bucket := aws.ToString(task.Destination)
+	taskPrefix := strings.TrimSuffix(aws.ToString(task.DestinationPrefix), "/") + "/" + event.TaskId + "/"
+	manifest := Manifest{
+		TaskId:       event.TaskId,
+		AccountId:    event.AccountId,
+		Region:       event.Region,
+		LogGroupName: aws.ToString(task.LogGroupName),
+		From:         time.UnixMilli(aws.ToInt64(task.From)).UTC(),
+		To:           time.UnixMilli(aws.ToInt64(task.To)).UTC(),
+		Bucket:       bucket,
+		Prefix:       taskPrefix,
+		Objects:      []ManifestObject{},
+		CreatedAt:    time.Now().UTC(),
+	}
+
+	paginator := s3.NewListObjectsV2Paginator(s3Client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(taskPrefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return ManifestResult{}, fmt.Errorf("error listing objects of export task %s: %w", event.TaskId, err)
+		}
+		for _, object := range page.Contents {
+			key := aws.ToString(object.Key)
+			if strings.HasSuffix(key, "/"+manifestName) {
+				continue
+			}
+			manifest.Objects = append(manifest.Objects, ManifestObject{Key: key, Size: aws.ToInt64(object.Size)})
+			manifest.TotalBytes += aws.ToInt64(object.Size)
+		}
+	}
+
+	group, err := describeLogGroup(ctx, cwLogsClient, manifest.LogGroupName)
+	if err != nil {
+		log.Printf("Could not describe log group, verifying without an expected size: %v", err)
+	}
+	manifest.Verification = verifyExport(group, manifest.From, manifest.To, len(manifest.Objects), manifest.TotalBytes, manifest.CreatedAt)
+	if manifest.Verification.Status != "OK" {
+		log.Printf("Export task %s is %s: %s", event.TaskId, manifest.Verification.Status, manifest.Verification.Reason)
+	}
+	if err := recordVerification(ctx, event.Region, event.itemKey(), event.TaskId, manifest.Verification, manifest.CreatedAt); err != nil {
+		return ManifestResult{}, err
+	}
+	if err := verifyRunExport(ctx, event.RunId, event.TaskId, manifest.Verification); err != nil {
+		log.Printf("Error recording run history: %v", err)
+	}
+
+	body, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return ManifestResult{}, fmt.Errorf("error marshalling manifest: %w", err)
+	}
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(taskPrefix + manifestName),
+		Body:        bytes.NewReader(body),
+		ContentType: aws.String("application/json"),
+	}
+	if regionConfig.KmsKeyId != "" {
+		input.ServerSideEncryption = s3types.ServerSideEncryptionAwsKms
+		input.SSEKMSKeyId = aws.String(regionConfig.KmsKeyId)
+	}
+	if _, err := s3Client.PutObject(ctx, input); err != nil {
+		return ManifestResult{}, fmt.Errorf("error writing manifest of export task %s: %w", event.TaskId, err)
+	}
+
+	putMetrics(map[string]string{"Action": "writeManifest", "Region": event.Region},
+		metric{Name: "BytesExported", Unit: unitBytes, Value: float64(manifest.TotalBytes)},
+	)
+	log.Printf("Wrote manifest s3://%s/%s%s with %d objects, %d bytes", bucket, taskPrefix, manifestName, len(manifest.Objects), manifest.TotalBytes)
+	return ManifestResult{
+		ManifestKey:        taskPrefix + manifestName,
+		Objects:            len(manifest.Objects),
+		TotalBytes:         manifest.TotalBytes,
+		Verification:       manifest.Verification.Status,
+		VerificationReason: manifest.Verification.Reason,
+	}, nil
+}
@@ -0,0 +1,121 @@
+package exporter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dynamodbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const metricsNamespace = "CloudWatchLogExporter"
+
+// itemStatuses are the statuses queue depth is reported for.
+var itemStatuses = []string{"PENDING", "IN_PROGRESS", "CHUNKED", "COMPLETED", "UP_TO_DATE", "SKIPPED_EMPTY", "EXCLUDED", "DEAD_LETTER", "DELETED"}
+
+type metricUnit string
+
+const (
+	unitCount        metricUnit = "Count"
+	unitMilliseconds metricUnit = "Milliseconds"
+	unitBytes        metricUnit = "Bytes"
+)
+
+type metric struct {
+	Name  string
+	Unit  metricUnit
+	Value float64
+}
+
+func countMetric(name string, value int) metric {
+	return metric{Name: name, Unit: unitCount, Value: float64(value)}
+}
+
+// putMetrics writes the metrics as one line in the CloudWatch Embedded Metric
+// Format, which CloudWatch Logs turns into metrics without an agent. Empty
+// dimensions are left out.
+func putMetrics(dimensions map[string]string, metrics ...metric) {
+	record := map[string]interface{}{}
+	dimensionNames := []string{}
+	for name, value := range dimensions {
+		if value == "" {
+			continue
+		}
+		dimensionNames = append(dimensionNames, name)
+		record[name] = value
+	}
+	sort.Strings(dimensionNames)
+
+	definitions := make([]map[string]string, 0, len(metrics))
+	for _, m := range metrics {
+		definitions = append(definitions, map[string]string{"Name": m.Name, "Unit": string(m.Unit)})
+		record[m.Name] = m.Value
+	}
+	record["_aws"] = map[string]interface{}{
+		"Timestamp": time.Now().UnixMilli(),
+		"CloudWatchMetrics": []map[string]interface{}{{
+			"Namespace":  metricsNamespace,
+			"Dimensions": [][]string{dimensionNames},
+			"Metrics":    definitions,
+		}},
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		log.Printf("Error marshalling metrics: %v", err)
+		return
+	}
+	// The line must be plain JSON, without the timestamp prefix of the log package
+	fmt.Fprintln(metricsOutput, string(line))
+}
+
+// putInvocationMetrics reports an action's invocation, error and latency.
+func putInvocationMetrics(event Event, started time.Time, err error) {
+	failed := 0
+	if err != nil {
+		failed = 1
+	}
+	putMetrics(map[string]string{"Action": event.Action, "Region": event.Region},
+		countMetric("Invocations", 1),
+		countMetric("Errors", failed),
+		metric{Name: "Latency", Unit: unitMilliseconds, Value: float64(time.Since(started).Milliseconds())},
+	)
+}
+
+// putQueueDepthMetrics reports the number of items of each status in each region.
+func putQueueDepthMetrics(ctx context.Context, regions []string) {
+	for _, region := range regions {
+		for _, status := range itemStatuses {
+			input := &dynamodb.QueryInput{
+				TableName:              aws.String(tableName),
+				IndexName:              aws.String("RegionStatusIndex"),
+				KeyConditionExpression: aws.String("#region = :region AND ItemStatus = :status"),
+				ExpressionAttributeNames: map[string]string{
+					"#region": "Region",
+				},
+				ExpressionAttributeValues: map[string]dynamodbtypes.AttributeValue{
+					":region": &dynamodbtypes.AttributeValueMemberS{Value: region},
+					":status": &dynamodbtypes.AttributeValueMemberS{Value: status},
+				},
+				Select: dynamodbtypes.SelectCount,
+			}
+
+			depth := 0
+			paginator := dynamodb.NewQueryPaginator(dynamoClient, input)
+			for paginator.HasMorePages() {
+				page, err := paginator.NextPage(ctx)
+				if err != nil {
+					log.Printf("Error counting %s items in region %s: %v", status, region, err)
+					break
+				}
+				depth += int(page.Count)
+			}
+			putMetrics(map[string]string{"Region": region, "Status": status}, countMetric("QueueDepth", depth))
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"text/template"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	snstypes "github.com/aws/aws-sdk-go-v2/service/sns/types"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+)
+
+const (
+	// defaultWebhookBody suits Slack and Teams incoming webhooks.
+	defaultWebhookBody   = `{"text": {{json (printf "%s\n%s" .Subject .Message)}}}`
+	webhookAttempts      = 3
+	webhookSignatureName = "X-Signature-256"
+)
+
+// Notification is a message for the on-call channels. Attributes are string or
+// int values that backends able to filter on them, like SNS, attach to the
+// message. Data is the structured payload, e.g. a RunSummary, for webhook templates.
+type Notification struct {
+	Severity   string
+	Subject    string
+	Message    string
+	Attributes map[string]interface{}
+	Data       interface{}
+}
+
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+type snsNotifier struct {
+	topicArn string
+}
+
+func (s snsNotifier) Notify(ctx context.Context, n Notification) error {
+	input := &sns.PublishInput{
+		Message:           aws.String(n.Message),
+		TopicArn:          aws.String(s.topicArn),
+		MessageAttributes: map[string]snstypes.MessageAttributeValue{},
+	}
+	if n.Subject != "" {
+		input.Subject = aws.String(n.Subject)
+	}
+	if n.Severity != "" {
+		input.MessageAttributes["severity"] = snstypes.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String(n.Severity)}
+	}
+	for name, value := range n.Attributes {
+		switch v := value.(type) {
+		case int:
+			input.MessageAttributes[name] = snstypes.MessageAttributeValue{DataType: aws.String("Number"), StringValue: aws.String(strconv.Itoa(v))}
+		default:
+			input.MessageAttributes[name] = snstypes.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String(fmt.Sprint(v))}
+		}
+	}
+
+	if _, err := snsClient.Publish(ctx, input); err != nil {
+		return fmt.Errorf("error publishing to SNS: %w", err)
+	}
+	return nil
+}
+
+// webhookNotifier posts a JSON body rendered from a template to an HTTPS
+// endpoint. With a secret, the body is signed with HMAC-SHA256 in the
+// X-Signature-256 header as "sha256=<hex digest>".
+type webhookNotifier struct {
+	url    string
+	body   *template.Template
+	secret []byte
+	client *http.Client
+	// backoff is the delay before the first retry, doubled for every further one.
+	backoff time.Duration
+}
+
+func newWebhookNotifier(url, bodyTemplate string, secret []byte) (*webhookNotifier, error) {
+	if bodyTemplate == "" {
+		bodyTemplate = defaultWebhookBody
+	}
+	body, err := parseWebhookBody(bodyTemplate)
+	if err != nil {
+		return nil, err
+	}
+	return &webhookNotifier{
+		url:     url,
+		body:    body,
+		secret:  secret,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		backoff: time.Second,
+	}, nil
+}
+
+// parseWebhookBody parses a body template. The json function renders a value as
+// JSON, so messages are escaped properly: {"text": {{json .Message}}}.
+func parseWebhookBody(bodyTemplate string) (*template.Template, error) {
+	return template.New("body").Funcs(template.FuncMap{
+		"json": func(v interface{}) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+	}).Parse(bodyTemplate)
+}
+
+func (w *webhookNotifier) Notify(ctx context.Context, n Notification) error {
+	var body bytes.Buffer
+	if err := w.body.Execute(&body, n); err != nil {
+		return fmt.Errorf("error rendering webhook body: %w", err)
+	}
+	if !json.Valid(body.Bytes()) {
+		return fmt.Errorf("webhook body template did not render valid JSON")
+	}
+
+	backoff := w.backoff
+	var lastErr error
+	for attempt := 1; attempt <= webhookAttempts; attempt++ {
+		retry, err := w.post(ctx, body.Bytes())
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+		if !retry || attempt == webhookAttempts {
+			break
+		}
+
+		log.Printf("Webhook attempt %d failed, retrying in %s: %v", attempt, backoff, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+	return fmt.Errorf("error posting to webhook: %w", lastErr)
+}
+
+// post sends one request and tells whether a failure is worth retrying.
+func (w *webhookNotifier) post(ctx context.Context, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(w.secret) > 0 {
+		mac := hmac.New(sha256.New, w.secret)
+		mac.Write(body)
+		req.Header.Set(webhookSignatureName, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("webhook returned %s", resp.Status)
+	default:
+		return false, fmt.Errorf("webhook returned %s", resp.Status)
+	}
+}
+
+// notifiers returns the configured targets whose severities include the given
+// one. Without any configured target it falls back to the topic, or to the
+// stack's topic when none is given.
+func notifiers(ctx context.Context, severity, topicArn string) []Notifier {
+	if topicArn == "" {
+		topicArn = snsTopic
+	}
+
+	exporterConfig, err := getExporterConfig(ctx)
+	if err != nil {
+		log.Printf("Error getting exporter config, notifying %s only: %v", topicArn, err)
+		return []Notifier{snsNotifier{topicArn: topicArn}}
+	}
+	if len(exporterConfig.Notifications) == 0 {
+		return []Notifier{snsNotifier{topicArn: topicArn}}
+	}
+
+	routed := []Notifier{}
+	for _, target := range exporterConfig.Notifications {
+		if len(target.Severities) > 0 && !slices.Contains(target.Severities, severity) {
+			continue
+		}
+
+		switch target.Type {
+		case "sns":
+			routed = append(routed, snsNotifier{topicArn: target.TopicArn})
+		case "webhook":
+			var secret []byte
+			if target.SecretParameter != "" {
+				param, err := ssmClient.GetParameter(ctx, &ssm.GetParameterInput{
+					Name:           aws.String(target.SecretParameter),
+					WithDecryption: aws.Bool(true),
+				})
+				if err != nil {
+					log.Printf("Error getting webhook secret %s, skipping webhook: %v", target.SecretParameter, err)
+					continue
+				}
+				secret = []byte(aws.ToString(param.Parameter.Value))
+			}
+			notifier, err := newWebhookNotifier(target.Url, target.BodyTemplate, secret)
+			if err != nil {
+				log.Printf("Error creating webhook notifier, skipping it: %v", err)
+				continue
+			}
+			routed = append(routed, notifier)
+		}
+	}
+	return routed
+}
+
+// notify sends a notification to every target routed for its severity. It tries
+// all of them and returns the first error.
+func notify(ctx context.Context, topicArn string, n Notification) error {
+	var firstErr error
+	for _, notifier := range notifiers(ctx, n.Severity, topicArn) {
+		if err := notifier.Notify(ctx, n); err != nil {
+			log.Printf("Error sending notification: %v", err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
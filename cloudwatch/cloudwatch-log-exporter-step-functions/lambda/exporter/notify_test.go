@@ -0,0 +1,361 @@
+package exporter
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+)
+
+// webhookServer answers with the given status codes in turn, the last one
+// repeating, and records the requests it received.
+type webhookServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func newWebhookServer(t *testing.T, statuses ...int) *webhookServer {
+	s := &webhookServer{statuses: statuses}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		status := s.statuses[min(len(s.requests), len(s.statuses)-1)]
+		s.requests = append(s.requests, r)
+		s.bodies = append(s.bodies, body)
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func newTestWebhook(t *testing.T, url, bodyTemplate string, secret []byte) *webhookNotifier {
+	t.Helper()
+	notifier, err := newWebhookNotifier(url, bodyTemplate, secret)
+	if err != nil {
+		t.Fatalf("newWebhookNotifier: %v", err)
+	}
+	notifier.backoff = time.Millisecond
+	return notifier
+}
+
+func TestWebhookSignature(t *testing.T) {
+	server := newWebhookServer(t, http.StatusOK)
+	secret := []byte("s3cr3t")
+	notifier := newTestWebhook(t, server.URL, "", secret)
+
+	if err := notifier.Notify(context.Background(), Notification{Subject: "Run finished", Message: "3 exported"}); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	if len(server.requests) != 1 {
+		t.Fatalf("got %d requests, want 1", len(server.requests))
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(server.bodies[0])
+	want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	if got := server.requests[0].Header.Get(webhookSignatureName); got != want {
+		t.Errorf("%s = %q, want %q", webhookSignatureName, got, want)
+	}
+	if got := server.requests[0].Header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", got)
+	}
+}
+
+func TestWebhookWithoutSecretIsNotSigned(t *testing.T) {
+	server := newWebhookServer(t, http.StatusNoContent)
+	notifier := newTestWebhook(t, server.URL, "", nil)
+
+	if err := notifier.Notify(context.Background(), Notification{Message: "hello"}); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	if got := server.requests[0].Header.Get(webhookSignatureName); got != "" {
+		t.Errorf("%s = %q, want no signature", webhookSignatureName, got)
+	}
+}
+
+func TestWebhookRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		requests int
+		wantErr  bool
+	}{
+		{"success", []int{http.StatusOK}, 1, false},
+		{"server error then success", []int{http.StatusInternalServerError, http.StatusOK}, 2, false},
+		{"unavailable twice then success", []int{http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusOK}, 3, false},
+		{"throttled every time", []int{http.StatusTooManyRequests}, webhookAttempts, true},
+		{"server error every time", []int{http.StatusInternalServerError}, webhookAttempts, true},
+		{"bad request", []int{http.StatusBadRequest}, 1, true},
+		{"forbidden", []int{http.StatusForbidden, http.StatusOK}, 1, true},
+		{"not found", []int{http.StatusNotFound}, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newWebhookServer(t, tt.statuses...)
+			notifier := newTestWebhook(t, server.URL, "", nil)
+
+			err := notifier.Notify(context.Background(), Notification{Message: "hello"})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Notify() error = %v, want error %v", err, tt.wantErr)
+			}
+			if len(server.requests) != tt.requests {
+				t.Errorf("got %d requests, want %d", len(server.requests), tt.requests)
+			}
+		})
+	}
+}
+
+func TestWebhookRetryStopsWithContext(t *testing.T) {
+	server := newWebhookServer(t, http.StatusInternalServerError)
+	notifier := newTestWebhook(t, server.URL, "", nil)
+	notifier.backoff = time.Hour
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := notifier.Notify(ctx, Notification{Message: "hello"}); err != context.DeadlineExceeded {
+		t.Errorf("Notify() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if len(server.requests) != 1 {
+		t.Errorf("got %d requests, want 1", len(server.requests))
+	}
+}
+
+func TestWebhookBody(t *testing.T) {
+	message := "Export of \"/aws/lambda/app\" failed:\n\tAccessDenied <s3>"
+	tests := []struct {
+		name     string
+		template string
+		want     map[string]interface{}
+	}{
+		{
+			name:     "default",
+			template: "",
+			want:     map[string]interface{}{"text": "Export failed\n" + message},
+		},
+		{
+			name:     "fields",
+			template: `{"severity": {{json .Severity}}, "title": {{json .Subject}}, "body": {{json .Message}}}`,
+			want:     map[string]interface{}{"severity": "ERROR", "title": "Export failed", "body": message},
+		},
+		{
+			name:     "data",
+			template: `{"failed": {{json .Data.Failed}}, "regions": {{json .Data.Regions}}}`,
+			want:     map[string]interface{}{"failed": float64(2), "regions": []interface{}{"us-east-1", "eu-west-1"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newWebhookServer(t, http.StatusOK)
+			notifier := newTestWebhook(t, server.URL, tt.template, nil)
+
+			err := notifier.Notify(context.Background(), Notification{
+				Severity: severityError,
+				Subject:  "Export failed",
+				Message:  message,
+				Data: struct {
+					Failed  int
+					Regions []string
+				}{2, []string{"us-east-1", "eu-west-1"}},
+			})
+			if err != nil {
+				t.Fatalf("Notify: %v", err)
+			}
+
+			var got map[string]interface{}
+			if err := json.Unmarshal(server.bodies[0], &got); err != nil {
+				t.Fatalf("body %s is not JSON: %v", server.bodies[0], err)
+			}
+			gotJSON, _ := json.Marshal(got)
+			wantJSON, _ := json.Marshal(tt.want)
+			if string(gotJSON) != string(wantJSON) {
+				t.Errorf("body = %s, want %s", gotJSON, wantJSON)
+			}
+		})
+	}
+}
+
+func TestWebhookBodyMustBeJSON(t *testing.T) {
+	server := newWebhookServer(t, http.StatusOK)
+	// Without the json function the quotes of the message break the body
+	notifier := newTestWebhook(t, server.URL, `{"text": "{{.Message}}"}`, nil)
+
+	err := notifier.Notify(context.Background(), Notification{Message: `say "hi"`})
+	if err == nil || !strings.Contains(err.Error(), "valid JSON") {
+		t.Errorf("Notify() error = %v, want an invalid JSON error", err)
+	}
+	if len(server.requests) != 0 {
+		t.Errorf("got %d requests, want none", len(server.requests))
+	}
+}
+
+func TestParseWebhookBody(t *testing.T) {
+	if _, err := parseWebhookBody(`{"text": {{json .Message}}}`); err != nil {
+		t.Errorf("parseWebhookBody() error = %v", err)
+	}
+	if _, err := parseWebhookBody(`{"text": {{json .Message}`); err == nil {
+		t.Error("parseWebhookBody() accepted an unterminated action")
+	}
+}
+
+// useParameters points the SSM client at a fake serving the given parameters
+// for the duration of the test.
+func useParameters(t *testing.T, parameters map[string]string) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var input struct{ Name string }
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		value, ok := parameters[input.Name]
+		if !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"__type": "ParameterNotFound", "message": input.Name})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"Parameter": map[string]string{"Name": input.Name, "Type": "String", "Value": value},
+		})
+	}))
+	t.Cleanup(server.Close)
+
+	previousClient, previousParam, previousTopic := ssmClient, ssmParamName, snsTopic
+	t.Cleanup(func() { ssmClient, ssmParamName, snsTopic = previousClient, previousParam, previousTopic })
+	ssmClient = ssm.New(ssm.Options{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String(server.URL),
+		Credentials:  aws.AnonymousCredentials{},
+	})
+	ssmParamName = "/cloudwatch-log-exporter/config"
+	snsTopic = "arn:aws:sns:us-east-1:111111111111:default"
+}
+
+// describeNotifiers names each notifier by its type and destination.
+func describeNotifiers(notifiers []Notifier) []string {
+	described := []string{}
+	for _, notifier := range notifiers {
+		switch n := notifier.(type) {
+		case snsNotifier:
+			described = append(described, "sns "+n.topicArn)
+		case *webhookNotifier:
+			d := "webhook " + n.url
+			if len(n.secret) > 0 {
+				d += " signed with " + string(n.secret)
+			}
+			described = append(described, d)
+		}
+	}
+	return described
+}
+
+func TestNotifiersRouting(t *testing.T) {
+	useParameters(t, map[string]string{
+		"/cloudwatch-log-exporter/config": `{
+			"version": 1,
+			"regions": [{"region": "us-east-1", "bucket": "example-bucket"}],
+			"notifications": [
+				{"type": "sns", "topicArn": "arn:aws:sns:us-east-1:111111111111:oncall", "severities": ["ERROR"]},
+				{"type": "webhook", "url": "https://hooks.example.com/all"},
+				{"type": "webhook", "url": "https://hooks.example.com/signed", "severities": ["WARNING", "ERROR"], "secretParameter": "/cloudwatch-log-exporter/secret"},
+				{"type": "webhook", "url": "https://hooks.example.com/broken", "severities": ["INFO"], "secretParameter": "/cloudwatch-log-exporter/missing"}
+			]
+		}`,
+		"/cloudwatch-log-exporter/secret": "s3cr3t",
+	})
+
+	tests := []struct {
+		name     string
+		severity string
+		topicArn string
+		want     []string
+	}{
+		{
+			name:     "error",
+			severity: severityError,
+			want: []string{
+				"sns arn:aws:sns:us-east-1:111111111111:oncall",
+				"webhook https://hooks.example.com/all",
+				"webhook https://hooks.example.com/signed signed with s3cr3t",
+			},
+		},
+		{
+			name:     "warning",
+			severity: severityWarning,
+			want: []string{
+				"webhook https://hooks.example.com/all",
+				"webhook https://hooks.example.com/signed signed with s3cr3t",
+			},
+		},
+		{
+			// The webhook whose secret cannot be read is skipped
+			name:     "info",
+			severity: severityInfo,
+			want:     []string{"webhook https://hooks.example.com/all"},
+		},
+		{
+			// A topic given by the state machine does not bypass the routes
+			name:     "explicit topic",
+			severity: severityWarning,
+			topicArn: "arn:aws:sns:us-east-1:111111111111:explicit",
+			want: []string{
+				"webhook https://hooks.example.com/all",
+				"webhook https://hooks.example.com/signed signed with s3cr3t",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := describeNotifiers(notifiers(context.Background(), tt.severity, tt.topicArn))
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("notifiers() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNotifiersFallBackToTopic(t *testing.T) {
+	tests := []struct {
+		name       string
+		parameters map[string]string
+	}{
+		{"no notifications configured", map[string]string{
+			"/cloudwatch-log-exporter/config": `{"version": 1, "regions": [{"region": "us-east-1", "bucket": "example-bucket"}]}`,
+		}},
+		{"invalid configuration", map[string]string{
+			"/cloudwatch-log-exporter/config": `{"version": 2}`,
+		}},
+		{"missing configuration", map[string]string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useParameters(t, tt.parameters)
+			got := describeNotifiers(notifiers(context.Background(), severityError, ""))
+			want := []string{"sns arn:aws:sns:us-east-1:111111111111:default"}
+			if strings.Join(got, "\n") != strings.Join(want, "\n") {
+				t.Errorf("notifiers() = %q, want %q", got, want)
+			}
+
+			got = describeNotifiers(notifiers(context.Background(), severityError, "arn:aws:sns:us-east-1:111111111111:explicit"))
+			want = []string{"sns arn:aws:sns:us-east-1:111111111111:explicit"}
+			if strings.Join(got, "\n") != strings.Join(want, "\n") {
+				t.Errorf("notifiers() with a topic = %q, want %q", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+
+	"prefix"
+)
+
+// PlannedExport is what a run would do with a log group. Planned is false when
+// no export task would be created, for the reason in SkipReason.
+type PlannedExport struct {
+	AccountId    string    `json:"accountId"`
+	Region       string    `json:"region"`
+	LogGroupName string    `json:"logGroupName"`
+	Rule         string    `json:"rule,omitempty"`
+	ItemStatus   string    `json:"itemStatus,omitempty"`
+	Planned      bool      `json:"planned"`
+	SkipReason   string    `json:"skipReason,omitempty"`
+	From         time.Time `json:"from,omitempty"`
+	To           time.Time `json:"to,omitempty"`
+	Bucket       string    `json:"bucket,omitempty"`
+	Prefix       string    `json:"prefix,omitempty"`
+	Chunks       int       `json:"chunks,omitempty"`
+	// EstimatedBytes is spread evenly from the stored bytes of the log group;
+	// it is 0 when the log group reports none.
+	EstimatedBytes int64 `json:"estimatedBytes"`
+}
+
+type ExportPlan struct {
+	GeneratedAt    time.Time       `json:"generatedAt"`
+	Planned        int             `json:"planned"`
+	EstimatedBytes int64           `json:"estimatedBytes"`
+	LogGroups      []PlannedExport `json:"logGroups"`
+	// Errors lists the accounts and regions that could not be planned.
+	Errors []string `json:"errors"`
+}
+
+// planExport walks every configured account and region, optionally narrowed down
+// by the event to one of them, and applies the selection rules, watermarks and
+// chunking of a scheduled run. It only reads: no item is written and no export
+// task created, so it shows what enabling the exporter for an account would do.
+func planExport(ctx context.Context, event Event) (ExportPlan, error) {
+	exporterConfig, err := getExporterConfig(ctx)
+	if err != nil {
+		return ExportPlan{}, err
+	}
+
+	if _, ok := exporterConfig.regionConfig(event.Region); event.Region != "" && !ok {
+		return ExportPlan{}, newError(ErrInvalidRequest, "region %s is not configured", event.Region)
+	}
+	accounts := exporterConfig.accounts(ctx)
+	if event.AccountId != "" {
+		i := slices.IndexFunc(accounts, func(account AccountConfig) bool { return account.AccountId == event.AccountId })
+		if i < 0 {
+			return ExportPlan{}, newError(ErrInvalidRequest, "account %s is not configured", event.AccountId)
+		}
+		accounts = accounts[i : i+1]
+	}
+
+	now := time.Now().UTC()
+	plan := ExportPlan{GeneratedAt: now, LogGroups: []PlannedExport{}, Errors: []string{}}
+	for _, account := range accounts {
+		for _, rbm := range exporterConfig.Regions {
+			if event.Region != "" && rbm.Region != event.Region {
+				continue
+			}
+
+			planned, err := planRegion(ctx, exporterConfig, account, rbm.Region, event.RunId, now)
+			if err != nil {
+				log.Printf("Error planning account %s in region %s: %v", account.AccountId, rbm.Region, err)
+				plan.Errors = append(plan.Errors, fmt.Sprintf("account %s, region %s: %v", account.AccountId, rbm.Region, err))
+			}
+			plan.LogGroups = append(plan.LogGroups, planned...)
+		}
+	}
+
+	for _, export := range plan.LogGroups {
+		if export.Planned {
+			plan.Planned++
+			plan.EstimatedBytes += export.EstimatedBytes
+		}
+	}
+	log.Printf("Planned %d of %d log groups, about %d bytes", plan.Planned, len(plan.LogGroups), plan.EstimatedBytes)
+	return plan, nil
+}
+
+func planRegion(ctx context.Context, exporterConfig ExporterConfig, account AccountConfig, region, runID string, now time.Time) ([]PlannedExport, error) {
+	regionConfig, _ := exporterConfig.regionConfig(region)
+	cwLogsClient, err := newCWLogsClient(ctx, account, region)
+	if err != nil {
+		return nil, err
+	}
+
+	// Watermarks of log groups exported before; a new account has none
+	items, err := queryAccountItems(ctx, account.AccountId, region)
+	if err != nil {
+		return nil, err
+	}
+	scheduled := map[string]LogGroup{}
+	for _, item := range items {
+		if item.Kind == "" {
+			scheduled[item.LogGroupName] = item
+		}
+	}
+
+	planned := []PlannedExport{}
+	paginator := cloudwatchlogs.NewDescribeLogGroupsPaginator(cwLogsClient, &cloudwatchlogs.DescribeLogGroupsInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return planned, fmt.Errorf("error listing log groups: %w", err)
+		}
+
+		for i := range page.LogGroups {
+			group := &page.LogGroups[i]
+			logGroupName := aws.ToString(group.LogGroupName)
+			item := scheduled[logGroupName]
+			export := PlannedExport{
+				AccountId:    account.AccountId,
+				Region:       region,
+				LogGroupName: logGroupName,
+				ItemStatus:   item.ItemStatus,
+			}
+
+			tags := getLogGroupTags(ctx, cwLogsClient, logGroupArn(account.AccountId, region, logGroupName))
+			selection := regionConfig.selectLogGroup(*group, tags, now)
+			export.Rule = selection.Rule
+			switch {
+			case !selection.Selected:
+				export.SkipReason = "excluded"
+			case item.ItemStatus == "DEAD_LETTER":
+				export.SkipReason = "dead-lettered: " + item.LastError
+			case item.ItemStatus == "CHUNKED":
+				export.SkipReason = "chunks of the previous window are pending"
+			}
+			if export.SkipReason != "" {
+				planned = append(planned, export)
+				continue
+			}
+
+			export.From, export.To = exportWindow(item.ExportedThrough, regionConfig.ExportDays, now)
+			if !export.From.Before(export.To) {
+				export.SkipReason = "up to date"
+				planned = append(planned, export)
+				continue
+			}
+			reason, _, err := emptyWindowReason(ctx, cwLogsClient, group, export.From, export.To, now)
+			if err != nil {
+				log.Printf("Could not tell whether the export window of %s is empty: %v", logGroupName, err)
+			}
+			if reason != "" {
+				export.SkipReason = reason
+				planned = append(planned, export)
+				continue
+			}
+
+			export.Planned = true
+			export.Bucket = regionConfig.Bucket
+			export.Prefix = prefix.Expand(regionConfig.PrefixTemplate, prefix.Fields{
+				Account:  account.AccountId,
+				Region:   region,
+				LogGroup: logGroupName,
+				RunID:    runID,
+				Time:     export.From,
+			})
+			export.EstimatedBytes, _ = estimateWindowBytes(group, export.From, export.To, now)
+			if chunks := chunkCount(regionConfig, group, export.From, export.To, now); chunks > 1 {
+				export.Chunks = chunks
+			}
+			planned = append(planned, export)
+		}
+	}
+	return planned, nil
+}
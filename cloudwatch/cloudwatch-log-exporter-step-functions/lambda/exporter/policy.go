@@ -0,0 +1,171 @@
+package exporter
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+)
+
+// policyDocument is the subset of an IAM policy document the preflight checks
+// evaluate. Statements, principals, actions and resources may each be a single
+// value or a list.
+type policyDocument struct {
+	Statement policyStatements `json:"Statement"`
+}
+
+type policyStatement struct {
+	Effect    string                           `json:"Effect"`
+	Principal policyPrincipal                  `json:"Principal"`
+	Action    stringList                       `json:"Action"`
+	Resource  stringList                       `json:"Resource"`
+	Condition map[string]map[string]stringList `json:"Condition"`
+}
+
+type policyStatements []policyStatement
+
+func (s *policyStatements) UnmarshalJSON(b []byte) error {
+	if len(b) > 0 && b[0] == '{' {
+		var statement policyStatement
+		if err := json.Unmarshal(b, &statement); err != nil {
+			return err
+		}
+		*s = policyStatements{statement}
+		return nil
+	}
+	return json.Unmarshal(b, (*[]policyStatement)(s))
+}
+
+// policyPrincipal is "*" or a map of principal types, e.g. "Service", to values.
+type policyPrincipal struct {
+	Any      bool
+	Services stringList
+}
+
+func (p *policyPrincipal) UnmarshalJSON(b []byte) error {
+	var any string
+	if err := json.Unmarshal(b, &any); err == nil {
+		p.Any = any == "*"
+		return nil
+	}
+	var principals map[string]stringList
+	if err := json.Unmarshal(b, &principals); err != nil {
+		return err
+	}
+	p.Services = principals["Service"]
+	return nil
+}
+
+// stringList is a value or list of values. Condition values may also be
+// numbers or booleans, which are kept in their JSON form.
+type stringList []string
+
+func (l *stringList) UnmarshalJSON(b []byte) error {
+	var values []json.RawMessage
+	if err := json.Unmarshal(b, &values); err != nil {
+		values = []json.RawMessage{b}
+	}
+	*l = make(stringList, len(values))
+	for i, value := range values {
+		if err := json.Unmarshal(value, &(*l)[i]); err != nil {
+			(*l)[i] = string(value)
+		}
+	}
+	return nil
+}
+
+func parsePolicy(raw string) (policyDocument, error) {
+	var doc policyDocument
+	err := json.Unmarshal([]byte(raw), &doc)
+	return doc, err
+}
+
+// grants tells whether an Allow statement grants service the action on the
+// resource. Conditions on the keys in context, e.g. aws:sourceaccount, are
+// evaluated against their values; conditions on other keys are assumed to hold.
+// When no statement grants it but one might under a condition operator that is
+// not evaluated, unverified is true.
+func (d policyDocument) grants(service, action, resource string, context map[string]string) (granted, unverified bool) {
+	for _, statement := range d.Statement {
+		if statement.Effect != "Allow" {
+			continue
+		}
+		applies, verified := statement.appliesTo(service, action, resource, context)
+		if applies && verified {
+			return true, false
+		}
+		unverified = unverified || applies
+	}
+	return false, unverified
+}
+
+// denies tells whether an unconditional Deny statement covers service, action and resource.
+func (d policyDocument) denies(service, action, resource string) bool {
+	for _, statement := range d.Statement {
+		if statement.Effect != "Deny" || len(statement.Condition) > 0 {
+			continue
+		}
+		if applies, _ := statement.appliesTo(service, action, resource, nil); applies {
+			return true
+		}
+	}
+	return false
+}
+
+// appliesTo tells whether the statement covers service, action and resource,
+// and whether its conditions could all be evaluated. A statement with an
+// operator other than those of conditionHolds applies, but is not verified.
+func (s policyStatement) appliesTo(service, action, resource string, context map[string]string) (applies, verified bool) {
+	if !s.Principal.Any && !matchesAny(service, s.Principal.Services) {
+		return false, true
+	}
+	if !matchesAny(strings.ToLower(action), lower(s.Action)) || !matchesAny(resource, s.Resource) {
+		return false, true
+	}
+	verified = true
+	for operator, values := range s.Condition {
+		for key, patterns := range values {
+			value, ok := context[strings.ToLower(key)]
+			holds, supported := conditionHolds(operator, value, ok, patterns)
+			if !supported {
+				verified = false
+				continue
+			}
+			if !holds {
+				return false, true
+			}
+		}
+	}
+	return true, verified
+}
+
+// conditionHolds evaluates the positive string and ARN operators, with or
+// without IfExists. A key without a value is assumed to match, since the
+// preflight cannot know every key CloudWatch Logs sends.
+func conditionHolds(operator, value string, ok bool, patterns []string) (holds, supported bool) {
+	switch strings.TrimSuffix(operator, "IfExists") {
+	case "StringEquals":
+		return !ok || slices.Contains(patterns, value), true
+	case "StringEqualsIgnoreCase":
+		return !ok || slices.ContainsFunc(patterns, func(pattern string) bool { return strings.EqualFold(pattern, value) }), true
+	case "StringLike", "ArnEquals", "ArnLike":
+		return !ok || matchesAny(value, patterns), true
+	}
+	return false, false
+}
+
+func matchesAny(value string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matchesGlob(value, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+func lower(values []string) []string {
+	lowered := make([]string, len(values))
+	for i, value := range values {
+		lowered[i] = strings.ToLower(value)
+	}
+	return lowered
+}
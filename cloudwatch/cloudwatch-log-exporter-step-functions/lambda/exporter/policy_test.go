@@ -0,0 +1,289 @@
+package exporter
+
+import (
+	"slices"
+	"testing"
+)
+
+const (
+	testService   = "logs.us-east-1.amazonaws.com"
+	testBucketArn = "arn:aws:s3:::example-bucket"
+	testObjectArn = "arn:aws:s3:::example-bucket/111111111111/app/aws-logs-write-test"
+)
+
+var testSource = map[string]string{
+	"aws:sourceaccount": "111111111111",
+	"aws:sourcearn":     "arn:aws:logs:us-east-1:111111111111:log-group:/aws/preflight:*",
+}
+
+func TestParsePolicy(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want []policyStatement
+	}{
+		{
+			name: "statement list",
+			raw: `{"Version": "2012-10-17", "Statement": [
+				{"Effect": "Allow", "Principal": {"Service": "logs.us-east-1.amazonaws.com"}, "Action": "s3:GetBucketAcl", "Resource": "arn:aws:s3:::example-bucket"},
+				{"Effect": "Allow", "Principal": {"Service": ["logs.us-east-1.amazonaws.com", "logs.us-west-2.amazonaws.com"]}, "Action": ["s3:PutObject"], "Resource": ["arn:aws:s3:::example-bucket/*"]}
+			]}`,
+			want: []policyStatement{
+				{Effect: "Allow", Principal: policyPrincipal{Services: stringList{"logs.us-east-1.amazonaws.com"}}, Action: stringList{"s3:GetBucketAcl"}, Resource: stringList{"arn:aws:s3:::example-bucket"}},
+				{Effect: "Allow", Principal: policyPrincipal{Services: stringList{"logs.us-east-1.amazonaws.com", "logs.us-west-2.amazonaws.com"}}, Action: stringList{"s3:PutObject"}, Resource: stringList{"arn:aws:s3:::example-bucket/*"}},
+			},
+		},
+		{
+			name: "single statement",
+			raw:  `{"Statement": {"Effect": "Deny", "Principal": "*", "Action": "s3:*", "Resource": "arn:aws:s3:::example-bucket/*"}}`,
+			want: []policyStatement{
+				{Effect: "Deny", Principal: policyPrincipal{Any: true}, Action: stringList{"s3:*"}, Resource: stringList{"arn:aws:s3:::example-bucket/*"}},
+			},
+		},
+		{
+			name: "AWS principal",
+			raw:  `{"Statement": [{"Effect": "Allow", "Principal": {"AWS": "arn:aws:iam::111111111111:root"}, "Action": "kms:*", "Resource": "*"}]}`,
+			want: []policyStatement{
+				{Effect: "Allow", Action: stringList{"kms:*"}, Resource: stringList{"*"}},
+			},
+		},
+		{
+			name: "non-string condition values",
+			raw:  `{"Statement": [{"Effect": "Deny", "Principal": "*", "Action": "s3:*", "Resource": "*", "Condition": {"Bool": {"aws:SecureTransport": false}, "NumericLessThan": {"s3:TlsVersion": [1.2]}}}]}`,
+			want: []policyStatement{
+				{Effect: "Deny", Principal: policyPrincipal{Any: true}, Action: stringList{"s3:*"}, Resource: stringList{"*"}, Condition: map[string]map[string]stringList{
+					"Bool":            {"aws:SecureTransport": {"false"}},
+					"NumericLessThan": {"s3:TlsVersion": {"1.2"}},
+				}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := parsePolicy(tt.raw)
+			if err != nil {
+				t.Fatalf("parsePolicy() error = %v", err)
+			}
+			if len(doc.Statement) != len(tt.want) {
+				t.Fatalf("got %d statements, want %d", len(doc.Statement), len(tt.want))
+			}
+			for i, got := range doc.Statement {
+				want := tt.want[i]
+				if got.Effect != want.Effect || got.Principal.Any != want.Principal.Any ||
+					!slices.Equal(got.Principal.Services, want.Principal.Services) ||
+					!slices.Equal(got.Action, want.Action) || !slices.Equal(got.Resource, want.Resource) {
+					t.Errorf("statement %d = %+v, want %+v", i, got, want)
+				}
+				for operator, values := range want.Condition {
+					for key, patterns := range values {
+						if !slices.Equal(got.Condition[operator][key], patterns) {
+							t.Errorf("statement %d condition %s %s = %q, want %q", i, operator, key, got.Condition[operator][key], patterns)
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestParsePolicyRejectsMalformedJSON(t *testing.T) {
+	if _, err := parsePolicy(`{"Statement": [`); err == nil {
+		t.Error("parsePolicy() accepted malformed JSON")
+	}
+}
+
+func TestPolicyGrants(t *testing.T) {
+	tests := []struct {
+		name           string
+		policy         string
+		action         string
+		resource       string
+		wantGranted    bool
+		wantUnverified bool
+	}{
+		{
+			name:        "exact statement",
+			policy:      `{"Statement": {"Effect": "Allow", "Principal": {"Service": "logs.us-east-1.amazonaws.com"}, "Action": "s3:GetBucketAcl", "Resource": "arn:aws:s3:::example-bucket"}}`,
+			action:      "s3:GetBucketAcl",
+			resource:    testBucketArn,
+			wantGranted: true,
+		},
+		{
+			name:        "wildcard resource and action case",
+			policy:      `{"Statement": {"Effect": "Allow", "Principal": {"Service": "logs.us-east-1.amazonaws.com"}, "Action": "S3:PutObject", "Resource": "arn:aws:s3:::example-bucket/*"}}`,
+			action:      "s3:PutObject",
+			resource:    testObjectArn,
+			wantGranted: true,
+		},
+		{
+			name:        "wildcard action",
+			policy:      `{"Statement": {"Effect": "Allow", "Principal": {"Service": "logs.us-east-1.amazonaws.com"}, "Action": "s3:*", "Resource": "arn:aws:s3:::example-bucket/*"}}`,
+			action:      "s3:PutObject",
+			resource:    testObjectArn,
+			wantGranted: true,
+		},
+		{
+			name:        "any principal",
+			policy:      `{"Statement": {"Effect": "Allow", "Principal": "*", "Action": "s3:PutObject", "Resource": "*"}}`,
+			action:      "s3:PutObject",
+			resource:    testObjectArn,
+			wantGranted: true,
+		},
+		{
+			name:     "other region's service",
+			policy:   `{"Statement": {"Effect": "Allow", "Principal": {"Service": "logs.us-west-2.amazonaws.com"}, "Action": "s3:PutObject", "Resource": "arn:aws:s3:::example-bucket/*"}}`,
+			action:   "s3:PutObject",
+			resource: testObjectArn,
+		},
+		{
+			name:     "other prefix",
+			policy:   `{"Statement": {"Effect": "Allow", "Principal": {"Service": "logs.us-east-1.amazonaws.com"}, "Action": "s3:PutObject", "Resource": "arn:aws:s3:::example-bucket/exportedlogs/*"}}`,
+			action:   "s3:PutObject",
+			resource: testObjectArn,
+		},
+		{
+			name:     "other action",
+			policy:   `{"Statement": {"Effect": "Allow", "Principal": {"Service": "logs.us-east-1.amazonaws.com"}, "Action": "s3:GetBucketAcl", "Resource": "arn:aws:s3:::example-bucket"}}`,
+			action:   "s3:PutObject",
+			resource: testObjectArn,
+		},
+		{
+			name:     "deny only",
+			policy:   `{"Statement": {"Effect": "Deny", "Principal": {"Service": "logs.us-east-1.amazonaws.com"}, "Action": "s3:PutObject", "Resource": "*"}}`,
+			action:   "s3:PutObject",
+			resource: testObjectArn,
+		},
+		{
+			name:        "matching source account",
+			policy:      `{"Statement": {"Effect": "Allow", "Principal": {"Service": "logs.us-east-1.amazonaws.com"}, "Action": "s3:PutObject", "Resource": "arn:aws:s3:::example-bucket/*", "Condition": {"StringEquals": {"aws:SourceAccount": ["111111111111", "222222222222"]}}}}`,
+			action:      "s3:PutObject",
+			resource:    testObjectArn,
+			wantGranted: true,
+		},
+		{
+			name:     "other source account",
+			policy:   `{"Statement": {"Effect": "Allow", "Principal": {"Service": "logs.us-east-1.amazonaws.com"}, "Action": "s3:PutObject", "Resource": "arn:aws:s3:::example-bucket/*", "Condition": {"StringEquals": {"aws:SourceAccount": "222222222222"}}}}`,
+			action:   "s3:PutObject",
+			resource: testObjectArn,
+		},
+		{
+			name:        "matching source ARN",
+			policy:      `{"Statement": {"Effect": "Allow", "Principal": {"Service": "logs.us-east-1.amazonaws.com"}, "Action": "s3:PutObject", "Resource": "arn:aws:s3:::example-bucket/*", "Condition": {"ArnLike": {"aws:SourceArn": "arn:aws:logs:us-east-1:111111111111:log-group:*"}}}}`,
+			action:      "s3:PutObject",
+			resource:    testObjectArn,
+			wantGranted: true,
+		},
+		{
+			name:        "source account ignoring case",
+			policy:      `{"Statement": {"Effect": "Allow", "Principal": {"Service": "logs.us-east-1.amazonaws.com"}, "Action": "s3:PutObject", "Resource": "arn:aws:s3:::example-bucket/*", "Condition": {"StringEqualsIgnoreCase": {"AWS:SOURCEACCOUNT": "111111111111"}}}}`,
+			action:      "s3:PutObject",
+			resource:    testObjectArn,
+			wantGranted: true,
+		},
+		{
+			name:        "IfExists operator",
+			policy:      `{"Statement": {"Effect": "Allow", "Principal": {"Service": "logs.us-east-1.amazonaws.com"}, "Action": "s3:PutObject", "Resource": "arn:aws:s3:::example-bucket/*", "Condition": {"StringLikeIfExists": {"aws:SourceArn": "arn:aws:logs:*:111111111111:*"}}}}`,
+			action:      "s3:PutObject",
+			resource:    testObjectArn,
+			wantGranted: true,
+		},
+		{
+			name:        "condition on an unknown key",
+			policy:      `{"Statement": {"Effect": "Allow", "Principal": {"Service": "logs.us-east-1.amazonaws.com"}, "Action": "s3:PutObject", "Resource": "arn:aws:s3:::example-bucket/*", "Condition": {"StringEquals": {"s3:x-amz-acl": "bucket-owner-full-control"}}}}`,
+			action:      "s3:PutObject",
+			resource:    testObjectArn,
+			wantGranted: true,
+		},
+		{
+			name:           "negated operator",
+			policy:         `{"Statement": {"Effect": "Allow", "Principal": {"Service": "logs.us-east-1.amazonaws.com"}, "Action": "s3:PutObject", "Resource": "arn:aws:s3:::example-bucket/*", "Condition": {"StringNotEquals": {"aws:SourceAccount": "111111111111"}}}}`,
+			action:         "s3:PutObject",
+			resource:       testObjectArn,
+			wantUnverified: true,
+		},
+		{
+			name:           "null operator",
+			policy:         `{"Statement": {"Effect": "Allow", "Principal": {"Service": "logs.us-east-1.amazonaws.com"}, "Action": "s3:PutObject", "Resource": "arn:aws:s3:::example-bucket/*", "Condition": {"Null": {"aws:SourceArn": "false"}}}}`,
+			action:         "s3:PutObject",
+			resource:       testObjectArn,
+			wantUnverified: true,
+		},
+		{
+			name:     "failing condition next to an unevaluated one",
+			policy:   `{"Statement": {"Effect": "Allow", "Principal": {"Service": "logs.us-east-1.amazonaws.com"}, "Action": "s3:PutObject", "Resource": "arn:aws:s3:::example-bucket/*", "Condition": {"ArnNotLike": {"aws:SourceArn": "arn:aws:logs:eu-west-1:*"}, "StringEquals": {"aws:SourceAccount": "222222222222"}}}}`,
+			action:   "s3:PutObject",
+			resource: testObjectArn,
+		},
+		{
+			name: "verified statement wins over an unverified one",
+			policy: `{"Statement": [
+				{"Effect": "Allow", "Principal": {"Service": "logs.us-east-1.amazonaws.com"}, "Action": "s3:PutObject", "Resource": "arn:aws:s3:::example-bucket/*", "Condition": {"ForAnyValue:StringEquals": {"aws:SourceAccount": "111111111111"}}},
+				{"Effect": "Allow", "Principal": {"Service": "logs.us-east-1.amazonaws.com"}, "Action": "s3:PutObject", "Resource": "arn:aws:s3:::example-bucket/*"}
+			]}`,
+			action:      "s3:PutObject",
+			resource:    testObjectArn,
+			wantGranted: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := parsePolicy(tt.policy)
+			if err != nil {
+				t.Fatalf("parsePolicy() error = %v", err)
+			}
+			granted, unverified := doc.grants(testService, tt.action, tt.resource, testSource)
+			if granted != tt.wantGranted || unverified != tt.wantUnverified {
+				t.Errorf("grants() = %v, %v, want %v, %v", granted, unverified, tt.wantGranted, tt.wantUnverified)
+			}
+		})
+	}
+}
+
+func TestPolicyDenies(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   string
+		resource string
+		want     bool
+	}{
+		{
+			name:     "deny everyone",
+			policy:   `{"Statement": {"Effect": "Deny", "Principal": "*", "Action": "s3:*", "Resource": "arn:aws:s3:::example-bucket/*"}}`,
+			resource: testObjectArn,
+			want:     true,
+		},
+		{
+			name:     "deny the service",
+			policy:   `{"Statement": [{"Effect": "Deny", "Principal": {"Service": "logs.us-east-1.amazonaws.com"}, "Action": ["s3:PutObject"], "Resource": ["arn:aws:s3:::example-bucket/*"]}]}`,
+			resource: testObjectArn,
+			want:     true,
+		},
+		{
+			// Denying insecure transport does not affect CloudWatch Logs
+			name:     "conditional deny",
+			policy:   `{"Statement": {"Effect": "Deny", "Principal": "*", "Action": "s3:*", "Resource": "arn:aws:s3:::example-bucket/*", "Condition": {"Bool": {"aws:SecureTransport": "false"}}}}`,
+			resource: testObjectArn,
+		},
+		{
+			name:     "deny another prefix",
+			policy:   `{"Statement": {"Effect": "Deny", "Principal": "*", "Action": "s3:PutObject", "Resource": "arn:aws:s3:::example-bucket/private/*"}}`,
+			resource: testObjectArn,
+		},
+		{
+			name:     "allow",
+			policy:   `{"Statement": {"Effect": "Allow", "Principal": "*", "Action": "s3:PutObject", "Resource": "*"}}`,
+			resource: testObjectArn,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := parsePolicy(tt.policy)
+			if err != nil {
+				t.Fatalf("parsePolicy() error = %v", err)
+			}
+			if got := doc.denies(testService, "s3:PutObject", tt.resource); got != tt.want {
+				t.Errorf("denies() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
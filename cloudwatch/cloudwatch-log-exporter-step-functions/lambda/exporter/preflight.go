@@ -0,0 +1,277 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	kmstypes "github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+
+	"prefix"
+)
+
+// Finding is a problem with a destination bucket. Findings with severity ERROR
+// would make every export task of the region fail and halt the run; WARNING
+// findings could not be verified or may fail later.
+type Finding struct {
+	Region   string `json:"region"`
+	Bucket   string `json:"bucket"`
+	Check    string `json:"check"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+type PreflightResult struct {
+	Passed   bool      `json:"passed"`
+	Findings []Finding `json:"findings"`
+}
+
+// preflightBuckets checks the destination bucket of every configured region
+// before any export task is created: the bucket must be in the region of its
+// log groups, its policy must let CloudWatch Logs check the ACL and write
+// objects, and an SSE-KMS key must be usable by CloudWatch Logs. Export tasks
+// report these misconfigurations only once they are created, and not always in
+// a way that names the cause.
+func preflightBuckets(ctx context.Context, event Event) (PreflightResult, error) {
+	exporterConfig, err := getExporterConfig(ctx)
+	if err != nil {
+		return PreflightResult{}, err
+	}
+	regionBucketMap, err := getRegionBucketMap(ctx)
+	if err != nil {
+		return PreflightResult{}, err
+	}
+	accounts := exporterConfig.accounts(ctx)
+
+	result := PreflightResult{Findings: []Finding{}}
+	for _, rbm := range regionBucketMap {
+		if event.Region != "" && rbm.Region != event.Region {
+			continue
+		}
+		regionConfig, _ := exporterConfig.regionConfig(rbm.Region)
+		result.Findings = append(result.Findings, checkBucket(ctx, regionConfig, accounts)...)
+	}
+
+	var fatal []string
+	for _, finding := range result.Findings {
+		log.Printf("Preflight %s for bucket %s in region %s (%s): %s", finding.Severity, finding.Bucket, finding.Region, finding.Check, finding.Message)
+		if finding.Severity == severityError {
+			fatal = append(fatal, fmt.Sprintf("%s (%s): %s", finding.Bucket, finding.Region, finding.Message))
+		}
+	}
+	if len(fatal) > 0 {
+		return result, newError(ErrDestinationUnavailable, "destination bucket preflight failed: %s", strings.Join(fatal, "; "))
+	}
+	result.Passed = true
+	log.Printf("Preflight passed for %d buckets with %d warnings", len(regionBucketMap), len(result.Findings))
+	return result, nil
+}
+
+func checkBucket(ctx context.Context, regionConfig RegionConfig, accounts []AccountConfig) []Finding {
+	region, bucket := regionConfig.Region, regionConfig.Bucket
+	finding := func(check, severity, format string, args ...interface{}) Finding {
+		return Finding{Region: region, Bucket: bucket, Check: check, Severity: severity, Message: fmt.Sprintf(format, args...)}
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	if err != nil {
+		return []Finding{finding("region", severityError, "error loading config for region %s: %v", region, err)}
+	}
+	s3Client := s3.NewFromConfig(cfg)
+
+	location, err := s3Client.GetBucketLocation(ctx, &s3.GetBucketLocationInput{Bucket: aws.String(bucket)})
+	switch {
+	case isErrorCode(err, "NoSuchBucket"):
+		return []Finding{finding("region", severityError, "bucket does not exist")}
+	case isErrorCode(err, "AccessDenied"):
+		return []Finding{finding("region", severityWarning, "cannot read the bucket location; grant s3:GetBucketLocation to check it")}
+	case err != nil:
+		return []Finding{finding("region", severityWarning, "error reading the bucket location: %v", err)}
+	}
+	if bucketRegion := locationRegion(location.LocationConstraint); bucketRegion != region {
+		// CloudWatch Logs only exports to buckets in the log group's region
+		return []Finding{finding("region", severityError, "bucket is in %s, export tasks need a bucket in %s", bucketRegion, region)}
+	}
+
+	findings := checkBucketPolicy(ctx, s3Client, regionConfig, accounts, finding)
+	return append(findings, checkBucketEncryption(ctx, cfg, s3Client, regionConfig, finding)...)
+}
+
+// locationRegion maps a bucket's location constraint to its region: buckets in
+// us-east-1 have none and the oldest buckets in eu-west-1 report "EU".
+func locationRegion(constraint s3types.BucketLocationConstraint) string {
+	switch constraint {
+	case "":
+		return "us-east-1"
+	case s3types.BucketLocationConstraintEu:
+		return "eu-west-1"
+	}
+	return string(constraint)
+}
+
+type findingFunc func(check, severity, format string, args ...interface{}) Finding
+
+func checkBucketPolicy(ctx context.Context, s3Client *s3.Client, regionConfig RegionConfig, accounts []AccountConfig, finding findingFunc) []Finding {
+	region, bucket := regionConfig.Region, regionConfig.Bucket
+	service := fmt.Sprintf("logs.%s.amazonaws.com", region)
+	bucketArn := "arn:aws:s3:::" + bucket
+	required := fmt.Sprintf("allow %s s3:GetBucketAcl on %s and s3:PutObject on %s/*", service, bucketArn, bucketArn)
+
+	output, err := s3Client.GetBucketPolicy(ctx, &s3.GetBucketPolicyInput{Bucket: aws.String(bucket)})
+	switch {
+	case isErrorCode(err, "NoSuchBucketPolicy"):
+		return []Finding{finding("policy", severityError, "bucket has no policy; add statements that %s", required)}
+	case isErrorCode(err, "AccessDenied"):
+		return []Finding{finding("policy", severityWarning, "cannot read the bucket policy; grant s3:GetBucketPolicy to check it")}
+	case err != nil:
+		return []Finding{finding("policy", severityWarning, "error reading the bucket policy: %v", err)}
+	}
+	policy, err := parsePolicy(aws.ToString(output.Policy))
+	if err != nil {
+		return []Finding{finding("policy", severityWarning, "cannot parse the bucket policy: %v", err)}
+	}
+
+	var findings []Finding
+	for _, account := range accounts {
+		objectPrefix := strings.Trim(prefix.Expand(regionConfig.PrefixTemplate, prefix.Fields{
+			Account:  account.AccountId,
+			Region:   region,
+			LogGroup: "/aws/preflight",
+			Time:     time.Now(),
+		}), "/")
+		// CloudWatch Logs writes this object before exporting anything
+		objectArn := fmt.Sprintf("%s/%s/aws-logs-write-test", bucketArn, objectPrefix)
+		source := map[string]string{
+			"aws:sourceaccount": account.AccountId,
+			"aws:sourcearn":     fmt.Sprintf("arn:aws:logs:%s:%s:log-group:/aws/preflight:*", region, account.AccountId),
+		}
+
+		if policy.denies(service, "s3:GetBucketAcl", bucketArn) || policy.denies(service, "s3:PutObject", objectArn) {
+			findings = append(findings, finding("policy", severityError, "bucket policy denies %s access to the bucket", service))
+			break
+		}
+		if granted, unverified := policy.grants(service, "s3:GetBucketAcl", bucketArn, source); !granted {
+			findings = append(findings, finding("policy", grantSeverity(unverified), "bucket policy does not allow %s s3:GetBucketAcl on %s for account %s%s", service, bucketArn, account.AccountId, unverifiedHint(unverified)))
+		}
+		if granted, unverified := policy.grants(service, "s3:PutObject", objectArn, source); !granted {
+			findings = append(findings, finding("policy", grantSeverity(unverified), "bucket policy does not allow %s s3:PutObject on %s/%s/* for account %s%s", service, bucketArn, objectPrefix, account.AccountId, unverifiedHint(unverified)))
+		}
+	}
+	return findings
+}
+
+func checkBucketEncryption(ctx context.Context, cfg aws.Config, s3Client *s3.Client, regionConfig RegionConfig, finding findingFunc) []Finding {
+	output, err := s3Client.GetBucketEncryption(ctx, &s3.GetBucketEncryptionInput{Bucket: aws.String(regionConfig.Bucket)})
+	switch {
+	case isErrorCode(err, "AccessDenied"):
+		return []Finding{finding("encryption", severityWarning, "cannot read the bucket encryption; grant s3:GetEncryptionConfiguration to check it")}
+	case err != nil:
+		return []Finding{finding("encryption", severityWarning, "error reading the bucket encryption: %v", err)}
+	}
+
+	var sse *s3types.ServerSideEncryptionByDefault
+	if output.ServerSideEncryptionConfiguration != nil {
+		for _, rule := range output.ServerSideEncryptionConfiguration.Rules {
+			if rule.ApplyServerSideEncryptionByDefault != nil {
+				sse = rule.ApplyServerSideEncryptionByDefault
+				break
+			}
+		}
+	}
+	if sse == nil || sse.SSEAlgorithm == s3types.ServerSideEncryptionAes256 {
+		if regionConfig.KmsKeyId != "" {
+			return []Finding{finding("encryption", severityWarning, "kmsKeyId is configured but the bucket does not encrypt with SSE-KMS by default")}
+		}
+		return nil
+	}
+	if sse.SSEAlgorithm == s3types.ServerSideEncryptionAwsKmsDsse {
+		return []Finding{finding("encryption", severityError, "export tasks do not support dual-layer SSE-KMS; use SSE-S3 or SSE-KMS")}
+	}
+
+	keyID := aws.ToString(sse.KMSMasterKeyID)
+	if keyID == "" {
+		return []Finding{finding("encryption", severityWarning, "bucket encrypts with the AWS managed key aws/s3, which CloudWatch Logs cannot be granted access to; use a customer managed key")}
+	}
+	return checkKey(ctx, kms.NewFromConfig(cfg), regionConfig, keyID, finding)
+}
+
+func checkKey(ctx context.Context, kmsClient *kms.Client, regionConfig RegionConfig, keyID string, finding findingFunc) []Finding {
+	region := regionConfig.Region
+	accessHint := "grant kms:DescribeKey and kms:GetKeyPolicy to check it"
+
+	key, err := kmsClient.DescribeKey(ctx, &kms.DescribeKeyInput{KeyId: aws.String(keyID)})
+	switch {
+	case isErrorCode(err, "AccessDeniedException"):
+		return []Finding{finding("encryption", severityWarning, "cannot describe bucket key %s; %s", keyID, accessHint)}
+	case isErrorCode(err, "NotFoundException"):
+		return []Finding{finding("encryption", severityError, "bucket key %s does not exist", keyID)}
+	case err != nil:
+		return []Finding{finding("encryption", severityWarning, "error describing bucket key %s: %v", keyID, err)}
+	}
+	metadata := key.KeyMetadata
+	keyArn := aws.ToString(metadata.Arn)
+
+	var findings []Finding
+	if metadata.KeyState != kmstypes.KeyStateEnabled {
+		findings = append(findings, finding("encryption", severityError, "bucket key %s is %s", keyArn, metadata.KeyState))
+	}
+	if !strings.HasPrefix(keyArn, fmt.Sprintf("arn:aws:kms:%s:", region)) {
+		findings = append(findings, finding("encryption", severityError, "bucket key %s is not in %s", keyArn, region))
+	}
+	if regionConfig.KmsKeyId != "" && regionConfig.KmsKeyId != keyArn && regionConfig.KmsKeyId != keyID {
+		findings = append(findings, finding("encryption", severityWarning, "kmsKeyId %s differs from the bucket key %s", regionConfig.KmsKeyId, keyArn))
+	}
+
+	keyPolicy, err := kmsClient.GetKeyPolicy(ctx, &kms.GetKeyPolicyInput{KeyId: aws.String(keyArn), PolicyName: aws.String("default")})
+	switch {
+	case isErrorCode(err, "AccessDeniedException"):
+		return append(findings, finding("encryption", severityWarning, "cannot read the policy of bucket key %s; %s", keyArn, accessHint))
+	case err != nil:
+		return append(findings, finding("encryption", severityWarning, "error reading the policy of bucket key %s: %v", keyArn, err))
+	}
+	policy, err := parsePolicy(aws.ToString(keyPolicy.Policy))
+	if err != nil {
+		return append(findings, finding("encryption", severityWarning, "cannot parse the policy of bucket key %s: %v", keyArn, err))
+	}
+	service := fmt.Sprintf("logs.%s.amazonaws.com", region)
+	granted, unverified := policy.grants(service, "kms:GenerateDataKey", keyArn, nil)
+	if !granted {
+		var globalUnverified bool
+		granted, globalUnverified = policy.grants("logs.amazonaws.com", "kms:GenerateDataKey", keyArn, nil)
+		unverified = unverified || globalUnverified
+	}
+	if !granted {
+		findings = append(findings, finding("encryption", grantSeverity(unverified), "policy of bucket key %s does not allow %s kms:GenerateDataKey%s", keyArn, service, unverifiedHint(unverified)))
+	}
+	return findings
+}
+
+// grantSeverity is the severity of a missing grant: a statement whose condition
+// operators the preflight cannot evaluate may still grant it, so the run goes on.
+func grantSeverity(unverified bool) string {
+	if unverified {
+		return severityWarning
+	}
+	return severityError
+}
+
+func unverifiedHint(unverified bool) string {
+	if unverified {
+		return ", unless a statement with condition operators the preflight does not evaluate allows it"
+	}
+	return ""
+}
+
+func isErrorCode(err error, code string) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == code
+}
@@ -0,0 +1,34 @@
+package exporter
+
+import (
+	"testing"
+
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+func TestLocationRegion(t *testing.T) {
+	tests := []struct {
+		constraint s3types.BucketLocationConstraint
+		want       string
+	}{
+		{"", "us-east-1"},
+		{s3types.BucketLocationConstraintEu, "eu-west-1"},
+		{s3types.BucketLocationConstraintEuWest1, "eu-west-1"},
+		{s3types.BucketLocationConstraintUsWest2, "us-west-2"},
+		{"ap-southeast-4", "ap-southeast-4"},
+	}
+	for _, tt := range tests {
+		if got := locationRegion(tt.constraint); got != tt.want {
+			t.Errorf("locationRegion(%q) = %q, want %q", tt.constraint, got, tt.want)
+		}
+	}
+}
+
+func TestGrantSeverity(t *testing.T) {
+	if got := grantSeverity(false); got != severityError {
+		t.Errorf("grantSeverity(false) = %q, want %q", got, severityError)
+	}
+	if got := grantSeverity(true); got != severityWarning {
+		t.Errorf("grantSeverity(true) = %q, want %q", got, severityWarning)
+	}
+}
@@ -0,0 +1,276 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dynamodbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// RegionInventory is the difference between the export table and the log
+// groups of an account and region. A renamed log group shows up as one deleted
+// and one discovered name.
+type RegionInventory struct {
+	Region     string   `json:"region"`
+	AccountId  string   `json:"accountId"`
+	Deleted    []string `json:"deleted"`
+	Discovered []string `json:"discovered"`
+	Error      string   `json:"error,omitempty"`
+}
+
+type InventoryDiff struct {
+	Deleted    int               `json:"deleted"`
+	Discovered int               `json:"discovered"`
+	Regions    []RegionInventory `json:"regions"`
+}
+
+// reconcileLogGroups compares the export table with DescribeLogGroups. Items of
+// log groups that no longer exist are marked DELETED, so getNextLogGroup stops
+// handing them out. Log groups listLogGroups first saw since the previous
+// reconciliation, and those it has not seen yet, are reported as discovered.
+// The diff is notified as INFO when it is not empty.
+func reconcileLogGroups(ctx context.Context, event Event) (InventoryDiff, error) {
+	exporterConfig, err := getExporterConfig(ctx)
+	if err != nil {
+		return InventoryDiff{}, err
+	}
+
+	now := time.Now()
+	diff := InventoryDiff{Regions: []RegionInventory{}}
+	for _, account := range exporterConfig.accounts(ctx) {
+		for _, rbm := range exporterConfig.Regions {
+			if event.Region != "" && rbm.Region != event.Region {
+				continue
+			}
+
+			inventory, err := reconcileRegion(ctx, account, rbm.Region, now)
+			if err != nil {
+				log.Printf("Error reconciling log groups of account %s in region %s: %v", account.AccountId, rbm.Region, err)
+				inventory.Error = err.Error()
+			}
+			diff.Deleted += len(inventory.Deleted)
+			diff.Discovered += len(inventory.Discovered)
+			diff.Regions = append(diff.Regions, inventory)
+		}
+	}
+	log.Printf("Reconciled log groups: %d deleted, %d discovered", diff.Deleted, diff.Discovered)
+
+	if diff.Deleted+diff.Discovered > 0 && !event.Quiet {
+		err := notify(ctx, event.TopicArn, Notification{
+			Severity: severityInfo,
+			Subject:  "CloudWatch log group inventory changes",
+			Message:  diff.String(),
+		})
+		if err != nil {
+			return InventoryDiff{}, err
+		}
+	}
+
+	return diff, nil
+}
+
+func reconcileRegion(ctx context.Context, account AccountConfig, region string, now time.Time) (RegionInventory, error) {
+	inventory := RegionInventory{
+		Region:     region,
+		AccountId:  account.AccountId,
+		Deleted:    []string{},
+		Discovered: []string{},
+	}
+
+	cwLogsClient, err := newCWLogsClient(ctx, account, region)
+	if err != nil {
+		return inventory, err
+	}
+
+	existing := map[string]bool{}
+	paginator := cloudwatchlogs.NewDescribeLogGroupsPaginator(cwLogsClient, &cloudwatchlogs.DescribeLogGroupsInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			// A partial listing would mark existing log groups as deleted
+			return inventory, fmt.Errorf("error listing log groups: %w", err)
+		}
+		for _, logGroup := range page.LogGroups {
+			existing[aws.ToString(logGroup.LogGroupName)] = true
+		}
+	}
+
+	items, err := queryAccountItems(ctx, account.AccountId, region)
+	if err != nil {
+		return inventory, err
+	}
+	since, err := lastReconciledAt(ctx, account.AccountId, region)
+	if err != nil {
+		return inventory, err
+	}
+
+	known := map[string]bool{}
+	for _, item := range items {
+		logGroupName := item.LogGroupName
+		if logGroupName == "" {
+			logGroupName = strings.TrimPrefix(item.Name, account.AccountId+":")
+		}
+		known[logGroupName] = true
+		if existing[logGroupName] && item.Kind == "" && !since.IsZero() && item.FirstSeenAt.After(since) {
+			inventory.Discovered = append(inventory.Discovered, logGroupName)
+		}
+		if existing[logGroupName] || item.ItemStatus == "DELETED" {
+			continue
+		}
+
+		deleted, err := markItemDeleted(ctx, item, now)
+		if err != nil {
+			return inventory, err
+		}
+		if deleted && item.Kind == "" {
+			inventory.Deleted = append(inventory.Deleted, logGroupName)
+		}
+	}
+
+	for logGroupName := range existing {
+		if !known[logGroupName] {
+			inventory.Discovered = append(inventory.Discovered, logGroupName)
+		}
+	}
+	sort.Strings(inventory.Discovered)
+	return inventory, recordReconciledAt(ctx, account.AccountId, region, now)
+}
+
+// reconciledItemName keys the item holding when an account was last reconciled
+// in a region. Log group names cannot contain "@", so it never collides with an
+// item of a log group.
+func reconciledItemName(accountID string) string {
+	return "@reconciled:" + accountID
+}
+
+// lastReconciledAt returns when the account was last reconciled in the region,
+// or the zero time before its first reconciliation.
+func lastReconciledAt(ctx context.Context, accountID, region string) (time.Time, error) {
+	output, err := dynamoClient.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]dynamodbtypes.AttributeValue{
+			"Region": &dynamodbtypes.AttributeValueMemberS{Value: region},
+			"Name":   &dynamodbtypes.AttributeValueMemberS{Value: reconciledItemName(accountID)},
+		},
+	})
+	if err != nil {
+		return time.Time{}, fmt.Errorf("error reading last reconciliation: %w", err)
+	}
+
+	var marker struct{ ReconciledAt time.Time }
+	if err := attributevalue.UnmarshalMap(output.Item, &marker); err != nil {
+		return time.Time{}, fmt.Errorf("error unmarshalling DynamoDB item: %w", err)
+	}
+	return marker.ReconciledAt, nil
+}
+
+func recordReconciledAt(ctx context.Context, accountID, region string, now time.Time) error {
+	_, err := dynamoClient.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]dynamodbtypes.AttributeValue{
+			"Region": &dynamodbtypes.AttributeValueMemberS{Value: region},
+			"Name":   &dynamodbtypes.AttributeValueMemberS{Value: reconciledItemName(accountID)},
+		},
+		UpdateExpression: aws.String("SET ReconciledAt = :now"),
+		ExpressionAttributeValues: map[string]dynamodbtypes.AttributeValue{
+			":now": &dynamodbtypes.AttributeValueMemberS{Value: now.UTC().Format(time.RFC3339)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("error recording reconciliation: %w", err)
+	}
+	return nil
+}
+
+// queryAccountItems returns the scheduled and backfill items of an account in a region.
+func queryAccountItems(ctx context.Context, accountID, region string) ([]LogGroup, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(tableName),
+		KeyConditionExpression: aws.String("#region = :region AND begins_with(#name, :prefix)"),
+		ExpressionAttributeNames: map[string]string{
+			"#region": "Region",
+			"#name":   "Name",
+		},
+		ExpressionAttributeValues: map[string]dynamodbtypes.AttributeValue{
+			":region": &dynamodbtypes.AttributeValueMemberS{Value: region},
+			":prefix": &dynamodbtypes.AttributeValueMemberS{Value: itemName(accountID, "")},
+		},
+	}
+
+	items := []LogGroup{}
+	paginator := dynamodb.NewQueryPaginator(dynamoClient, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error querying DynamoDB: %w", err)
+		}
+
+		var logGroups []LogGroup
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &logGroups); err != nil {
+			return nil, fmt.Errorf("error unmarshalling DynamoDB items: %w", err)
+		}
+		items = append(items, logGroups...)
+	}
+	return items, nil
+}
+
+// markItemDeleted marks the item of a log group that no longer exists. Items
+// another execution holds a live lease on are left to finish; their export
+// fails and the next reconciliation marks them.
+func markItemDeleted(ctx context.Context, item LogGroup, now time.Time) (bool, error) {
+	_, err := dynamoClient.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]dynamodbtypes.AttributeValue{
+			"Region": &dynamodbtypes.AttributeValueMemberS{Value: item.Region},
+			"Name":   &dynamodbtypes.AttributeValueMemberS{Value: item.Name},
+		},
+		UpdateExpression:    aws.String("SET ItemStatus = :deleted, DeletedAt = :now"),
+		ConditionExpression: aws.String("ItemStatus <> :inprogress OR LeaseExpiresAt < :now"),
+		ExpressionAttributeValues: map[string]dynamodbtypes.AttributeValue{
+			":deleted":    &dynamodbtypes.AttributeValueMemberS{Value: "DELETED"},
+			":inprogress": &dynamodbtypes.AttributeValueMemberS{Value: "IN_PROGRESS"},
+			":now":        &dynamodbtypes.AttributeValueMemberS{Value: now.UTC().Format(time.RFC3339)},
+		},
+	})
+	if err != nil {
+		var conditionFailed *dynamodbtypes.ConditionalCheckFailedException
+		if errors.As(err, &conditionFailed) {
+			log.Printf("Log group %s is being exported by another execution", item.Name)
+			return false, nil
+		}
+		return false, fmt.Errorf("error marking log group %s deleted: %w", item.Name, err)
+	}
+	log.Printf("Marked %s in region %s as DELETED", item.Name, item.Region)
+	return true, nil
+}
+
+func (d InventoryDiff) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d log groups deleted, %d discovered\n", d.Deleted, d.Discovered)
+	for _, inventory := range d.Regions {
+		if inventory.Error != "" {
+			fmt.Fprintf(&b, "\n%s %s: not reconciled: %s\n", inventory.AccountId, inventory.Region, inventory.Error)
+			continue
+		}
+		if len(inventory.Deleted)+len(inventory.Discovered) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\n%s %s:\n", inventory.AccountId, inventory.Region)
+		for _, name := range inventory.Deleted {
+			fmt.Fprintf(&b, "  - %s\n", name)
+		}
+		for _, name := range inventory.Discovered {
+			fmt.Fprintf(&b, "  + %s\n", name)
+		}
+	}
+	return b.String()
+}
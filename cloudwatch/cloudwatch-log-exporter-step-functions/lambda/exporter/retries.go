@@ -0,0 +1,163 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dynamodbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	defaultMaxAttempts           = 5
+	defaultRetryBaseDelayMinutes = 15
+	// maxRetryDoublings caps the retry delay at 512 times the base delay
+	maxRetryDoublings = 9
+)
+
+// retryPolicy returns the attempt limit and base delay, with defaults applied.
+func (c ExporterConfig) retryPolicy() (int, time.Duration) {
+	maxAttempts := c.MaxAttempts
+	if maxAttempts == 0 {
+		maxAttempts = defaultMaxAttempts
+	}
+	baseDelay := c.RetryBaseDelayMinutes
+	if baseDelay == 0 {
+		baseDelay = defaultRetryBaseDelayMinutes
+	}
+	return maxAttempts, time.Duration(baseDelay) * time.Minute
+}
+
+// retryDelay doubles the base delay with every failed attempt after the first,
+// up to 512 times the base delay. Attempts below 1 count as the first one, and
+// a delay too long for a time.Duration is capped at the longest one.
+func retryDelay(baseDelay time.Duration, attempts int) time.Duration {
+	doublings := min(max(attempts-1, 0), maxRetryDoublings)
+	if baseDelay > math.MaxInt64>>doublings {
+		return math.MaxInt64
+	}
+	return baseDelay << doublings
+}
+
+// recordFailure counts a failed attempt on the item. It requeues the item with a
+// NotBefore delay until the attempt limit is reached and then moves it to
+// DEAD_LETTER, where it stays until retryDeadLetters resets it.
+func recordFailure(ctx context.Context, input *dynamodb.UpdateItemInput, event Event, now time.Time) (string, int, error) {
+	item, err := getLogGroupItem(ctx, event.Region, event.itemKey())
+	if err != nil {
+		return "", 0, err
+	}
+
+	maxAttempts, baseDelay := defaultMaxAttempts, time.Duration(defaultRetryBaseDelayMinutes)*time.Minute
+	if exporterConfig, err := getExporterConfig(ctx); err != nil {
+		log.Printf("Error getting exporter config, using the default retry policy: %v", err)
+	} else {
+		maxAttempts, baseDelay = exporterConfig.retryPolicy()
+	}
+
+	attempts := item.Attempts + 1
+	itemStatus := "PENDING"
+	notBefore := now.Add(retryDelay(baseDelay, attempts))
+	if attempts >= maxAttempts {
+		itemStatus = "DEAD_LETTER"
+	}
+
+	lastError := failureMessage(event)
+
+	*input.UpdateExpression += ", #attempts = :attempts, #lastError = :lasterror, #notBefore = :notbefore"
+	input.ExpressionAttributeNames["#attempts"] = "Attempts"
+	input.ExpressionAttributeNames["#lastError"] = "LastError"
+	input.ExpressionAttributeNames["#notBefore"] = "NotBefore"
+	input.ExpressionAttributeValues[":itemstatus"] = &dynamodbtypes.AttributeValueMemberS{Value: itemStatus}
+	input.ExpressionAttributeValues[":attempts"] = &dynamodbtypes.AttributeValueMemberN{Value: strconv.Itoa(attempts)}
+	input.ExpressionAttributeValues[":lasterror"] = &dynamodbtypes.AttributeValueMemberS{Value: lastError}
+	input.ExpressionAttributeValues[":notbefore"] = &dynamodbtypes.AttributeValueMemberS{Value: notBefore.UTC().Format(time.RFC3339)}
+
+	log.Printf("Export of %s failed (attempt %d of %d), moving it to %s", event.itemKey(), attempts, maxAttempts, itemStatus)
+	return itemStatus, attempts, nil
+}
+
+// failureMessage is the error recorded for a failed export.
+func failureMessage(event Event) string {
+	if event.Message != "" {
+		return event.Message
+	}
+	return fmt.Sprintf("export task %s ended with status %s", event.TaskId, event.Status)
+}
+
+type RetryResult struct {
+	Reset []string `json:"reset"`
+}
+
+// retryStatus is the status a dead-lettered item is reset to. A parent
+// dead-lettered for one of its chunks keeps waiting for them in CHUNKED.
+func retryStatus(item LogGroup) string {
+	if len(item.PendingChunks) > 0 {
+		return "CHUNKED"
+	}
+	return "PENDING"
+}
+
+// retryDeadLetters returns DEAD_LETTER items to PENDING, or CHUNKED, with a fresh retry budget.
+// The region and log group name of the event narrow down which items are reset.
+func retryDeadLetters(ctx context.Context, event Event) (RetryResult, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(tableName),
+		IndexName:              aws.String("ItemStatusIndex"),
+		KeyConditionExpression: aws.String("ItemStatus = :deadletter"),
+		ExpressionAttributeValues: map[string]dynamodbtypes.AttributeValue{
+			":deadletter": &dynamodbtypes.AttributeValueMemberS{Value: "DEAD_LETTER"},
+		},
+	}
+
+	reset := []string{}
+	paginator := dynamodb.NewQueryPaginator(dynamoClient, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return RetryResult{}, fmt.Errorf("error querying dead-lettered items: %w", err)
+		}
+
+		var logGroups []LogGroup
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &logGroups); err != nil {
+			return RetryResult{}, fmt.Errorf("error unmarshalling DynamoDB items: %w", err)
+		}
+
+		for _, logGroup := range logGroups {
+			if event.Region != "" && logGroup.Region != event.Region {
+				continue
+			}
+			if event.LogGroupName != "" && logGroup.LogGroupName != event.LogGroupName && logGroup.Name != event.LogGroupName {
+				continue
+			}
+
+			_, err := dynamoClient.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+				TableName: aws.String(tableName),
+				Key: map[string]dynamodbtypes.AttributeValue{
+					"Region": &dynamodbtypes.AttributeValueMemberS{Value: logGroup.Region},
+					"Name":   &dynamodbtypes.AttributeValueMemberS{Value: logGroup.Name},
+				},
+				UpdateExpression:    aws.String("SET ItemStatus = :pending, Attempts = :zero REMOVE NotBefore"),
+				ConditionExpression: aws.String("ItemStatus = :deadletter"),
+				ExpressionAttributeValues: map[string]dynamodbtypes.AttributeValue{
+					":pending":    &dynamodbtypes.AttributeValueMemberS{Value: retryStatus(logGroup)},
+					":deadletter": &dynamodbtypes.AttributeValueMemberS{Value: "DEAD_LETTER"},
+					":zero":       &dynamodbtypes.AttributeValueMemberN{Value: "0"},
+				},
+			})
+			if err != nil {
+				return RetryResult{}, fmt.Errorf("error resetting dead-lettered log group %s: %w", logGroup.Name, err)
+			}
+			reset = append(reset, logGroup.Region+"/"+logGroup.Name)
+		}
+	}
+
+	log.Printf("Reset %d dead-lettered items", len(reset))
+	return RetryResult{Reset: reset}, nil
+}
@@ -0,0 +1,67 @@
+package exporter
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		baseDelay time.Duration
+		attempts  int
+		want      time.Duration
+	}{
+		{15 * time.Minute, 0, 15 * time.Minute},
+		{15 * time.Minute, -1, 15 * time.Minute},
+		{15 * time.Minute, 1, 15 * time.Minute},
+		{15 * time.Minute, 2, 30 * time.Minute},
+		{15 * time.Minute, 4, 2 * time.Hour},
+		{time.Minute, 10, 512 * time.Minute},
+		{time.Minute, 11, 512 * time.Minute},
+		{time.Minute, 100, 512 * time.Minute},
+		{time.Minute, math.MaxInt, 512 * time.Minute},
+		{time.Duration(math.MaxInt64 / 4), 10, time.Duration(math.MaxInt64)},
+	}
+	for _, tt := range tests {
+		if got := retryDelay(tt.baseDelay, tt.attempts); got != tt.want {
+			t.Errorf("retryDelay(%s, %d) = %s, want %s", tt.baseDelay, tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestRetryStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		item LogGroup
+		want string
+	}{
+		{"scheduled item", LogGroup{Name: "111111111111:/app"}, "PENDING"},
+		{"chunk", LogGroup{Name: "111111111111:/app#chunk#2026-03-07T00:00:00Z"}, "PENDING"},
+		{"parent of a dead-lettered chunk", LogGroup{Name: "111111111111:/app", PendingChunks: []string{"111111111111:/app#chunk#2026-03-07T00:00:00Z"}}, "CHUNKED"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := retryStatus(tt.item); got != tt.want {
+				t.Errorf("retryStatus() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	tests := []struct {
+		config      ExporterConfig
+		maxAttempts int
+		baseDelay   time.Duration
+	}{
+		{ExporterConfig{}, defaultMaxAttempts, defaultRetryBaseDelayMinutes * time.Minute},
+		{ExporterConfig{MaxAttempts: 3, RetryBaseDelayMinutes: 5}, 3, 5 * time.Minute},
+	}
+	for _, tt := range tests {
+		maxAttempts, baseDelay := tt.config.retryPolicy()
+		if maxAttempts != tt.maxAttempts || baseDelay != tt.baseDelay {
+			t.Errorf("retryPolicy() = %d, %s, want %d, %s", maxAttempts, baseDelay, tt.maxAttempts, tt.baseDelay)
+		}
+	}
+}
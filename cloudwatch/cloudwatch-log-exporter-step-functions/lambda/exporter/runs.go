@@ -0,0 +1,167 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dynamodbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// RunExport records one export task started by a run. Records live in the runs
+// table, keyed by RunId and TaskId, so the log group items only carry the
+// current state while the history of every run is kept.
+type RunExport struct {
+	RunId        string    `json:"runId"`
+	TaskId       string    `json:"taskId"`
+	Region       string    `json:"region"`
+	AccountId    string    `json:"accountId"`
+	LogGroupName string    `json:"logGroupName"`
+	ItemName     string    `json:"itemName"`
+	From         time.Time `json:"from"`
+	To           time.Time `json:"to"`
+	ExportStatus string    `json:"exportStatus"`
+	StartedAt    time.Time `json:"startedAt"`
+	FinishedAt   time.Time `json:"finishedAt,omitempty"`
+	// ItemStatus is the status the log group moved to, e.g. PENDING for a retry or
+	// DEAD_LETTER, and Error the reason of a failed export.
+	ItemStatus         string `json:"itemStatus,omitempty"`
+	Error              string `json:"error,omitempty"`
+	Bytes              int64  `json:"bytes,omitempty"`
+	Verification       string `json:"verification,omitempty"`
+	VerificationReason string `json:"verificationReason,omitempty"`
+}
+
+type RunReport struct {
+	RunId   string         `json:"runId"`
+	Total   int            `json:"total"`
+	Totals  map[string]int `json:"totals"`
+	Exports []RunExport    `json:"exports"`
+}
+
+// recordRunExport stores the export task just created for a run. It is a no-op
+// for invocations outside a run, e.g. manual createExportTask calls.
+func recordRunExport(ctx context.Context, export RunExport) error {
+	if export.RunId == "" || runsTableName == "" {
+		return nil
+	}
+
+	item, err := attributevalue.MarshalMap(export)
+	if err != nil {
+		return fmt.Errorf("error marshalling run export: %w", err)
+	}
+	_, err = dynamoClient.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(runsTableName),
+		Item:      item,
+	})
+	if err != nil {
+		return fmt.Errorf("error recording export task %s of run %s: %w", export.TaskId, export.RunId, err)
+	}
+	return nil
+}
+
+// finishRunExport stores the final status of an export task of a run.
+func finishRunExport(ctx context.Context, runID, taskID, status, itemStatus, message string, now time.Time) error {
+	return updateRunExport(ctx, runID, taskID, map[string]dynamodbtypes.AttributeValue{
+		"ExportStatus": &dynamodbtypes.AttributeValueMemberS{Value: status},
+		"ItemStatus":   &dynamodbtypes.AttributeValueMemberS{Value: itemStatus},
+		"Error":        &dynamodbtypes.AttributeValueMemberS{Value: message},
+		"FinishedAt":   &dynamodbtypes.AttributeValueMemberS{Value: now.UTC().Format(time.RFC3339)},
+	})
+}
+
+// verifyRunExport stores the bytes an export task of a run wrote and their verification.
+func verifyRunExport(ctx context.Context, runID, taskID string, v Verification) error {
+	return updateRunExport(ctx, runID, taskID, map[string]dynamodbtypes.AttributeValue{
+		"Bytes":              &dynamodbtypes.AttributeValueMemberN{Value: strconv.FormatInt(v.Bytes, 10)},
+		"Verification":       &dynamodbtypes.AttributeValueMemberS{Value: v.Status},
+		"VerificationReason": &dynamodbtypes.AttributeValueMemberS{Value: v.Reason},
+	})
+}
+
+func updateRunExport(ctx context.Context, runID, taskID string, attributes map[string]dynamodbtypes.AttributeValue) error {
+	if runID == "" || taskID == "" || runsTableName == "" {
+		return nil
+	}
+
+	names := make([]string, 0, len(attributes))
+	for name := range attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	set := make([]string, 0, len(names))
+	values := map[string]dynamodbtypes.AttributeValue{}
+	for i, name := range names {
+		placeholder := fmt.Sprintf(":v%d", i)
+		set = append(set, name+" = "+placeholder)
+		values[placeholder] = attributes[name]
+	}
+
+	_, err := dynamoClient.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(runsTableName),
+		Key: map[string]dynamodbtypes.AttributeValue{
+			"RunId":  &dynamodbtypes.AttributeValueMemberS{Value: runID},
+			"TaskId": &dynamodbtypes.AttributeValueMemberS{Value: taskID},
+		},
+		UpdateExpression:          aws.String("SET " + strings.Join(set, ", ")),
+		ConditionExpression:       aws.String("attribute_exists(TaskId)"),
+		ExpressionAttributeValues: values,
+	})
+	if err != nil {
+		return fmt.Errorf("error updating export task %s of run %s: %w", taskID, runID, err)
+	}
+	return nil
+}
+
+// getRunReport returns the export tasks of a run with totals by status.
+func getRunReport(ctx context.Context, event Event) (RunReport, error) {
+	return queryRunReport(ctx, event.RunId)
+}
+
+func queryRunReport(ctx context.Context, runID string) (RunReport, error) {
+	if runID == "" {
+		return RunReport{}, newError(ErrInvalidRequest, "a run report requires a runId")
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(runsTableName),
+		KeyConditionExpression: aws.String("RunId = :runid"),
+		ExpressionAttributeValues: map[string]dynamodbtypes.AttributeValue{
+			":runid": &dynamodbtypes.AttributeValueMemberS{Value: runID},
+		},
+	}
+
+	report := RunReport{
+		RunId:   runID,
+		Totals:  map[string]int{},
+		Exports: []RunExport{},
+	}
+	paginator := dynamodb.NewQueryPaginator(dynamoClient, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return RunReport{}, fmt.Errorf("error querying exports of run %s: %w", runID, err)
+		}
+
+		var exports []RunExport
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &exports); err != nil {
+			return RunReport{}, fmt.Errorf("error unmarshalling DynamoDB items: %w", err)
+		}
+		for _, export := range exports {
+			report.Totals[export.ExportStatus]++
+		}
+		report.Exports = append(report.Exports, exports...)
+	}
+	report.Total = len(report.Exports)
+
+	log.Printf("Run %s started %d export tasks: %v", report.RunId, report.Total, report.Totals)
+	return report, nil
+}
@@ -0,0 +1,233 @@
+package exporter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+)
+
+// SelectionRule includes or excludes the log groups matching all of its conditions.
+// Tags holds expressions of the form "key", "!key", "key=value" or "key!=value";
+// values may use * as a wildcard.
+type SelectionRule struct {
+	Name           string   `json:"name"`
+	Action         string   `json:"action"`
+	NamePrefix     string   `json:"namePrefix,omitempty"`
+	NameRegex      string   `json:"nameRegex,omitempty"`
+	Tags           []string `json:"tags,omitempty"`
+	LogGroupClass  string   `json:"logGroupClass,omitempty"`
+	MinStoredBytes int64    `json:"minStoredBytes,omitempty"`
+	MinAgeDays     int      `json:"minAgeDays,omitempty"`
+	MaxAgeDays     int      `json:"maxAgeDays,omitempty"`
+
+	// nameRegexp and tagConditions are compiled from NameRegex and Tags by
+	// validate, so matching does not compile them for every log group.
+	nameRegexp    *regexp.Regexp
+	tagConditions []tagCondition
+}
+
+// tagCondition is a compiled tag expression. Without a value it tests whether
+// the key exists; negated turns "key" into "!key" and "key=value" into
+// "key!=value".
+type tagCondition struct {
+	key     string
+	value   *regexp.Regexp
+	negated bool
+}
+
+type Selection struct {
+	Selected bool
+	Rule     string
+}
+
+// autoBackupRule keeps honouring the auto-backup=no tag ahead of any configured rule.
+var autoBackupRule = func() SelectionRule {
+	rule := SelectionRule{
+		Name:   "auto-backup=no",
+		Action: "exclude",
+		Tags:   []string{"auto-backup=no"},
+	}
+	if err := rule.compile(); err != nil {
+		panic(err)
+	}
+	return rule
+}()
+
+// selectLogGroup evaluates the selection rules of a region in order and returns the
+// decision of the first matching rule. The include and exclude patterns are applied
+// after the rules; a log group that matches nothing is selected unless include
+// patterns are configured.
+func (rc RegionConfig) selectLogGroup(logGroup types.LogGroup, tags map[string]string, now time.Time) Selection {
+	rules := append([]SelectionRule{autoBackupRule}, rc.Rules...)
+	rules = append(append(rules, rc.exclude...), rc.include...)
+
+	for _, rule := range rules {
+		if rule.matches(logGroup, tags, now) {
+			return Selection{Selected: rule.Action == "include", Rule: rule.Name}
+		}
+	}
+	if len(rc.Include) > 0 {
+		return Selection{Selected: false, Rule: "default-exclude"}
+	}
+	return Selection{Selected: true, Rule: "default-include"}
+}
+
+func (r SelectionRule) matches(logGroup types.LogGroup, tags map[string]string, now time.Time) bool {
+	name := aws.ToString(logGroup.LogGroupName)
+	if r.NamePrefix != "" && !strings.HasPrefix(name, r.NamePrefix) {
+		return false
+	}
+	if r.nameRegexp != nil && !r.nameRegexp.MatchString(name) {
+		return false
+	}
+	for _, condition := range r.tagConditions {
+		if !condition.matches(tags) {
+			return false
+		}
+	}
+	if r.LogGroupClass != "" && string(logGroup.LogGroupClass) != r.LogGroupClass {
+		return false
+	}
+	if r.MinStoredBytes > 0 && aws.ToInt64(logGroup.StoredBytes) < r.MinStoredBytes {
+		return false
+	}
+	if r.MinAgeDays > 0 || r.MaxAgeDays > 0 {
+		age := now.Sub(time.UnixMilli(aws.ToInt64(logGroup.CreationTime)))
+		if r.MinAgeDays > 0 && age < time.Duration(r.MinAgeDays)*24*time.Hour {
+			return false
+		}
+		if r.MaxAgeDays > 0 && age > time.Duration(r.MaxAgeDays)*24*time.Hour {
+			return false
+		}
+	}
+	return true
+}
+
+// compile compiles the name regex and tag expressions of the rule.
+func (r *SelectionRule) compile() error {
+	r.nameRegexp = nil
+	if r.NameRegex != "" {
+		nameRegexp, err := regexp.Compile(r.NameRegex)
+		if err != nil {
+			return fmt.Errorf("invalid nameRegex: %w", err)
+		}
+		r.nameRegexp = nameRegexp
+	}
+
+	r.tagConditions = make([]tagCondition, 0, len(r.Tags))
+	for _, expression := range r.Tags {
+		condition, err := parseTagExpression(expression)
+		if err != nil {
+			return fmt.Errorf("invalid tag expression %q: %w", expression, err)
+		}
+		r.tagConditions = append(r.tagConditions, condition)
+	}
+	return nil
+}
+
+func parseTagExpression(expression string) (tagCondition, error) {
+	if key, ok := strings.CutPrefix(expression, "!"); ok {
+		return tagCondition{key: key, negated: true}, nil
+	}
+	negated := true
+	key, pattern, ok := strings.Cut(expression, "!=")
+	if !ok {
+		negated = false
+		if key, pattern, ok = strings.Cut(expression, "="); !ok {
+			return tagCondition{key: expression}, nil
+		}
+	}
+
+	value, err := regexp.Compile(globRegexp(pattern))
+	if err != nil {
+		return tagCondition{}, err
+	}
+	return tagCondition{key: key, value: value, negated: negated}, nil
+}
+
+func (c tagCondition) matches(tags map[string]string) bool {
+	actual, exists := tags[c.key]
+	if c.value == nil {
+		return exists != c.negated
+	}
+	if c.negated {
+		return !exists || !c.value.MatchString(actual)
+	}
+	return exists && c.value.MatchString(actual)
+}
+
+// compilePatterns turns include or exclude globs into rules named after them.
+func compilePatterns(field, action string, patterns []string) ([]SelectionRule, []string) {
+	var problems []string
+	rules := make([]SelectionRule, 0, len(patterns))
+	for i, pattern := range patterns {
+		if strings.TrimSpace(pattern) == "" {
+			problems = append(problems, fmt.Sprintf("%s[%d]: empty pattern", field, i))
+			continue
+		}
+		rule := SelectionRule{Name: action + ":" + pattern, Action: action, NameRegex: globRegexp(pattern)}
+		if err := rule.compile(); err != nil {
+			problems = append(problems, fmt.Sprintf("%s[%d]: %v", field, i, err))
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	return rules, problems
+}
+
+// globRegexp turns a glob where * matches any run of characters, including /,
+// into an anchored regular expression.
+func globRegexp(pattern string) string {
+	return "^" + strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*") + "$"
+}
+
+func matchesGlob(value, pattern string) bool {
+	return regexp.MustCompile(globRegexp(pattern)).MatchString(value)
+}
+
+// validateRules checks the rules and compiles them in place.
+func validateRules(field string, rules []SelectionRule) []string {
+	var problems []string
+	seen := map[string]bool{}
+	for i, rule := range rules {
+		ruleField := fmt.Sprintf("%s[%d]", field, i)
+		if rule.Name == "" {
+			problems = append(problems, fmt.Sprintf("%s: missing name", ruleField))
+		} else if seen[rule.Name] {
+			problems = append(problems, fmt.Sprintf("%s: duplicate rule name %q", ruleField, rule.Name))
+		}
+		seen[rule.Name] = true
+
+		if rule.Action != "include" && rule.Action != "exclude" {
+			problems = append(problems, fmt.Sprintf("%s: action must be include or exclude, got %q", ruleField, rule.Action))
+		}
+		if err := rules[i].compile(); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", ruleField, err))
+		}
+		for _, expression := range rule.Tags {
+			if strings.Trim(expression, "!=") == "" || strings.HasPrefix(expression, "=") {
+				problems = append(problems, fmt.Sprintf("%s: invalid tag expression %q", ruleField, expression))
+			}
+		}
+		switch types.LogGroupClass(rule.LogGroupClass) {
+		case "", types.LogGroupClassStandard, types.LogGroupClassInfrequentAccess:
+		default:
+			problems = append(problems, fmt.Sprintf("%s: unknown logGroupClass %q", ruleField, rule.LogGroupClass))
+		}
+		if rule.MinStoredBytes < 0 || rule.MinAgeDays < 0 || rule.MaxAgeDays < 0 {
+			problems = append(problems, fmt.Sprintf("%s: minStoredBytes, minAgeDays and maxAgeDays must not be negative", ruleField))
+		}
+		if rule.MaxAgeDays > 0 && rule.MinAgeDays > rule.MaxAgeDays {
+			problems = append(problems, fmt.Sprintf("%s: minAgeDays is greater than maxAgeDays", ruleField))
+		}
+		if rule.NamePrefix == "" && rule.NameRegex == "" && len(rule.Tags) == 0 && rule.LogGroupClass == "" &&
+			rule.MinStoredBytes == 0 && rule.MinAgeDays == 0 && rule.MaxAgeDays == 0 {
+			problems = append(problems, fmt.Sprintf("%s: rule has no conditions", ruleField))
+		}
+	}
+	return problems
+}
@@ -0,0 +1,230 @@
+package exporter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+)
+
+func TestMatchesGlob(t *testing.T) {
+	tests := []struct {
+		value, pattern string
+		want           bool
+	}{
+		{"/aws/lambda/app", "/aws/lambda/app", true},
+		{"/aws/lambda/app", "/aws/lambda/*", true},
+		{"/aws/lambda/app/errors", "/aws/lambda/*", true},
+		{"/aws/lambda/app", "/aws/ecs/*", false},
+		{"/aws/lambda/app", "*app", true},
+		{"/aws/lambda/app-1", "*app", false},
+		{"prod", "pr*d", true},
+		{"a.b", "a.b", true},
+		{"axb", "a.b", false},
+		{"a+b", "a+b", true},
+		{"", "*", true},
+		{"", "", true},
+		{"x", "", false},
+	}
+	for _, tt := range tests {
+		if got := matchesGlob(tt.value, tt.pattern); got != tt.want {
+			t.Errorf("matchesGlob(%q, %q) = %v, want %v", tt.value, tt.pattern, got, tt.want)
+		}
+	}
+}
+
+// compiledRegionConfig validates a region's selection like parseExporterConfig
+// does, which compiles its rules and patterns.
+func compiledRegionConfig(t *testing.T, rc RegionConfig) RegionConfig {
+	t.Helper()
+	rc.Region, rc.Bucket = "us-east-1", "example-bucket"
+	c := ExporterConfig{Version: exporterConfigVersion, Regions: []RegionConfig{rc}}
+	if problems := c.validate(); len(problems) > 0 {
+		t.Fatalf("validate() = %q", problems)
+	}
+	compiled, _ := c.regionConfig(rc.Region)
+	return compiled
+}
+
+func TestSelectLogGroup(t *testing.T) {
+	now := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+	group := func(name string, class types.LogGroupClass, storedBytes int64, ageDays int) types.LogGroup {
+		return types.LogGroup{
+			LogGroupName:  aws.String(name),
+			LogGroupClass: class,
+			StoredBytes:   aws.Int64(storedBytes),
+			CreationTime:  aws.Int64(now.AddDate(0, 0, -ageDays).UnixMilli()),
+		}
+	}
+	standard := group("/aws/lambda/app", types.LogGroupClassStandard, 1024, 10)
+
+	tests := []struct {
+		name   string
+		config RegionConfig
+		group  types.LogGroup
+		tags   map[string]string
+		want   Selection
+	}{
+		{
+			name:  "no rules",
+			group: standard,
+			want:  Selection{Selected: true, Rule: "default-include"},
+		},
+		{
+			name:  "auto-backup=no tag",
+			group: standard,
+			tags:  map[string]string{"auto-backup": "no"},
+			want:  Selection{Selected: false, Rule: "auto-backup=no"},
+		},
+		{
+			name:   "auto-backup=no ahead of an include rule",
+			config: RegionConfig{Rules: []SelectionRule{{Name: "all", Action: "include", NamePrefix: "/"}}},
+			group:  standard,
+			tags:   map[string]string{"auto-backup": "no"},
+			want:   Selection{Selected: false, Rule: "auto-backup=no"},
+		},
+		{
+			name: "first matching rule wins",
+			config: RegionConfig{Rules: []SelectionRule{
+				{Name: "lambda", Action: "exclude", NamePrefix: "/aws/lambda/"},
+				{Name: "all", Action: "include", NamePrefix: "/"},
+			}},
+			group: standard,
+			want:  Selection{Selected: false, Rule: "lambda"},
+		},
+		{
+			name:   "name regex",
+			config: RegionConfig{Rules: []SelectionRule{{Name: "apps", Action: "exclude", NameRegex: `^/aws/lambda/a.p$`}}},
+			group:  standard,
+			want:   Selection{Selected: false, Rule: "apps"},
+		},
+		{
+			name:   "tag value glob",
+			config: RegionConfig{Rules: []SelectionRule{{Name: "prod", Action: "exclude", Tags: []string{"env=prod*"}}}},
+			group:  standard,
+			tags:   map[string]string{"env": "production"},
+			want:   Selection{Selected: false, Rule: "prod"},
+		},
+		{
+			name:   "negated tag value holds without the tag",
+			config: RegionConfig{Rules: []SelectionRule{{Name: "not-prod", Action: "exclude", Tags: []string{"env!=prod"}}}},
+			group:  standard,
+			want:   Selection{Selected: false, Rule: "not-prod"},
+		},
+		{
+			name:   "missing tag",
+			config: RegionConfig{Rules: []SelectionRule{{Name: "untagged", Action: "exclude", Tags: []string{"!team"}}}},
+			group:  standard,
+			tags:   map[string]string{"team": "core"},
+			want:   Selection{Selected: true, Rule: "default-include"},
+		},
+		{
+			name:   "all conditions must match",
+			config: RegionConfig{Rules: []SelectionRule{{Name: "big-lambda", Action: "exclude", NamePrefix: "/aws/lambda/", MinStoredBytes: 2048}}},
+			group:  standard,
+			want:   Selection{Selected: true, Rule: "default-include"},
+		},
+		{
+			name:   "log group class",
+			config: RegionConfig{Rules: []SelectionRule{{Name: "ia", Action: "exclude", LogGroupClass: "INFREQUENT_ACCESS"}}},
+			group:  group("/aws/lambda/app", types.LogGroupClassInfrequentAccess, 1024, 10),
+			want:   Selection{Selected: false, Rule: "ia"},
+		},
+		{
+			name:   "too young",
+			config: RegionConfig{Rules: []SelectionRule{{Name: "old", Action: "exclude", MinAgeDays: 30}}},
+			group:  standard,
+			want:   Selection{Selected: true, Rule: "default-include"},
+		},
+		{
+			name:   "within age range",
+			config: RegionConfig{Rules: []SelectionRule{{Name: "recent", Action: "exclude", MinAgeDays: 7, MaxAgeDays: 14}}},
+			group:  standard,
+			want:   Selection{Selected: false, Rule: "recent"},
+		},
+		{
+			name:   "exclude pattern",
+			config: RegionConfig{Exclude: []string{"/aws/lambda/*"}},
+			group:  standard,
+			want:   Selection{Selected: false, Rule: "exclude:/aws/lambda/*"},
+		},
+		{
+			name:   "exclude pattern ahead of include pattern",
+			config: RegionConfig{Include: []string{"/aws/*"}, Exclude: []string{"/aws/lambda/*"}},
+			group:  standard,
+			want:   Selection{Selected: false, Rule: "exclude:/aws/lambda/*"},
+		},
+		{
+			name:   "include pattern",
+			config: RegionConfig{Include: []string{"/aws/*"}},
+			group:  standard,
+			want:   Selection{Selected: true, Rule: "include:/aws/*"},
+		},
+		{
+			name:   "not included",
+			config: RegionConfig{Include: []string{"/ecs/*"}},
+			group:  standard,
+			want:   Selection{Selected: false, Rule: "default-exclude"},
+		},
+		{
+			name: "rules ahead of patterns",
+			config: RegionConfig{
+				Rules:   []SelectionRule{{Name: "app", Action: "include", NamePrefix: "/aws/lambda/app"}},
+				Exclude: []string{"/aws/lambda/*"},
+			},
+			group: standard,
+			want:  Selection{Selected: true, Rule: "app"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := compiledRegionConfig(t, tt.config).selectLogGroup(tt.group, tt.tags, now); got != tt.want {
+				t.Errorf("selectLogGroup() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectionFromParsedConfig(t *testing.T) {
+	now := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+	cfg, problems := parseExporterConfig(`{
+		"version": 1,
+		"include": ["/aws/*"],
+		"exclude": ["/aws/lambda/tmp-*"],
+		"rules": [{"name": "apps", "action": "exclude", "nameRegex": "^/aws/lambda/a.p$", "tags": ["team!=core"]}],
+		"regions": [{"region": "us-east-1", "bucket": "example-bucket"}]
+	}`)
+	if len(problems) > 0 {
+		t.Fatalf("parseExporterConfig() problems = %q", problems)
+	}
+	rc, _ := cfg.regionConfig("us-east-1")
+
+	tests := []struct {
+		name string
+		tags map[string]string
+		want Selection
+	}{
+		{"/aws/lambda/app", nil, Selection{Selected: false, Rule: "apps"}},
+		{"/aws/lambda/app", map[string]string{"team": "core"}, Selection{Selected: true, Rule: "include:/aws/*"}},
+		{"/aws/lambda/tmp-1", nil, Selection{Selected: false, Rule: "exclude:/aws/lambda/tmp-*"}},
+		{"/ecs/app", nil, Selection{Selected: false, Rule: "default-exclude"}},
+	}
+	for _, tt := range tests {
+		group := types.LogGroup{LogGroupName: aws.String(tt.name), CreationTime: aws.Int64(now.UnixMilli())}
+		if got := rc.selectLogGroup(group, tt.tags, now); got != tt.want {
+			t.Errorf("selectLogGroup(%s, %v) = %+v, want %+v", tt.name, tt.tags, got, tt.want)
+		}
+	}
+
+	// A malformed pattern is a validation problem rather than a panic when matching
+	_, problems = parseExporterConfig(`{
+		"version": 1,
+		"rules": [{"name": "broken", "action": "exclude", "nameRegex": "[a-"}],
+		"regions": [{"region": "us-east-1", "bucket": "example-bucket"}]
+	}`)
+	want := "rules[0]: invalid nameRegex: error parsing regexp: missing closing ]: `[a-`"
+	if len(problems) != 1 || problems[0] != want {
+		t.Errorf("parseExporterConfig() problems = %q, want %q", problems, want)
+	}
+}
@@ -0,0 +1,225 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dynamodbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const defaultMaxTaskAgeHours = 12
+
+// StaleTask is an export task that was PENDING or RUNNING for longer than the
+// region's maxTaskAgeHours. Only tasks of an exporter item are cancelled; tasks
+// without one, created by someone else, are reported and left running.
+type StaleTask struct {
+	TaskId       string `json:"taskId"`
+	AccountId    string `json:"accountId"`
+	Region       string `json:"region"`
+	LogGroupName string `json:"logGroupName"`
+	Status       string `json:"status"`
+	AgeMinutes   int    `json:"ageMinutes"`
+	Cancelled    bool   `json:"cancelled"`
+	ItemName     string `json:"itemName,omitempty"`
+	ItemStatus   string `json:"itemStatus,omitempty"`
+	Attempts     int    `json:"attempts,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+type StaleTasksResult struct {
+	Cancelled int         `json:"cancelled"`
+	Tasks     []StaleTask `json:"tasks"`
+}
+
+// cancelStaleTasks cancels export tasks that block their region's export slot
+// for too long and requeues their items like any other failed export, so they
+// are retried after a delay and count towards the attempt limit. The stale
+// tasks are reported to the event's topic or the configured notifiers, unless
+// the event is quiet.
+func cancelStaleTasks(ctx context.Context, event Event) (StaleTasksResult, error) {
+	exporterConfig, err := getExporterConfig(ctx)
+	if err != nil {
+		return StaleTasksResult{}, err
+	}
+
+	now := time.Now()
+	result := StaleTasksResult{Tasks: []StaleTask{}}
+	for _, account := range exporterConfig.accounts(ctx) {
+		for _, rbm := range exporterConfig.Regions {
+			if event.Region != "" && rbm.Region != event.Region {
+				continue
+			}
+
+			regionConfig, _ := exporterConfig.regionConfig(rbm.Region)
+			maxAge := time.Duration(regionConfig.MaxTaskAgeHours) * time.Hour
+			stale, err := cancelRegionStaleTasks(ctx, account, rbm.Region, maxAge, now)
+			if err != nil {
+				log.Printf("Error cancelling stale export tasks of account %s in region %s: %v", account.AccountId, rbm.Region, err)
+			}
+			result.Tasks = append(result.Tasks, stale...)
+		}
+	}
+	for _, task := range result.Tasks {
+		if task.Cancelled {
+			result.Cancelled++
+		}
+	}
+	log.Printf("Found %d stale export tasks, cancelled %d", len(result.Tasks), result.Cancelled)
+
+	if len(result.Tasks) > 0 && !event.Quiet {
+		err := notify(ctx, event.TopicArn, Notification{
+			Severity: severityWarning,
+			Subject:  fmt.Sprintf("Cancelled %d stale CloudWatch export tasks", result.Cancelled),
+			Message:  staleTasksMessage(result.Tasks),
+			Data:     result,
+		})
+		if err != nil {
+			return StaleTasksResult{}, err
+		}
+	}
+	return result, nil
+}
+
+func cancelRegionStaleTasks(ctx context.Context, account AccountConfig, region string, maxAge time.Duration, now time.Time) ([]StaleTask, error) {
+	tasks, err := describeActiveExportTasks(ctx, account, region)
+	if err != nil {
+		return nil, err
+	}
+	var stale []types.ExportTask
+	for _, task := range tasks {
+		created := creationTime(task)
+		if created != 0 && now.Sub(time.UnixMilli(created)) > maxAge {
+			stale = append(stale, task)
+		}
+	}
+	if len(stale) == 0 {
+		return nil, nil
+	}
+
+	items, err := queryAccountItems(ctx, account.AccountId, region)
+	if err != nil {
+		return nil, err
+	}
+	// The item may have been reaped back to PENDING by an earlier run; as long
+	// as the outcome of its task is not recorded, the task is still the item's
+	itemsByTask := map[string]LogGroup{}
+	for _, item := range items {
+		if item.TaskId != "" && item.TaskStatus == "" {
+			itemsByTask[item.TaskId] = item
+		}
+	}
+
+	cwLogsClient, err := newCWLogsClient(ctx, account, region)
+	if err != nil {
+		return nil, err
+	}
+
+	reported := []StaleTask{}
+	for _, task := range stale {
+		age := now.Sub(time.UnixMilli(creationTime(task)))
+		staleTask := StaleTask{
+			TaskId:       aws.ToString(task.TaskId),
+			AccountId:    account.AccountId,
+			Region:       region,
+			LogGroupName: aws.ToString(task.LogGroupName),
+			Status:       string(task.Status.Code),
+			AgeMinutes:   int(age.Minutes()),
+		}
+
+		item, ok := itemsByTask[staleTask.TaskId]
+		if !ok {
+			log.Printf("Stale export task %s was not created by the exporter, leaving it running", staleTask.TaskId)
+			reported = append(reported, staleTask)
+			continue
+		}
+		staleTask.ItemName = item.Name
+
+		_, err := cwLogsClient.CancelExportTask(ctx, &cloudwatchlogs.CancelExportTaskInput{
+			TaskId: task.TaskId,
+		})
+		if err != nil {
+			staleTask.Error = fmt.Sprintf("error cancelling export task: %v", err)
+			reported = append(reported, staleTask)
+			continue
+		}
+		staleTask.Cancelled = true
+
+		// The same update as for a task that failed on its own, under the lease of
+		// the execution that created it
+		update, err := updateDynamoDB(ctx, Event{
+			AccountId:    account.AccountId,
+			Region:       region,
+			LogGroupName: item.LogGroupName,
+			ItemName:     item.Name,
+			Owner:        item.LeaseOwner,
+			TaskId:       staleTask.TaskId,
+			Status:       string(types.ExportTaskStatusCodeCancelled),
+			Message:      fmt.Sprintf("cancelled after %s in status %s", age.Round(time.Minute), staleTask.Status),
+			StartTime:    aws.ToInt64(task.From),
+			EndTime:      aws.ToInt64(task.To),
+		})
+		if err != nil {
+			staleTask.Error = err.Error()
+		} else {
+			staleTask.ItemStatus = update.ItemStatus
+			staleTask.Attempts = update.Attempts
+		}
+		log.Printf("Cancelled stale export task %s of %s, moved it to %s", staleTask.TaskId, item.Name, staleTask.ItemStatus)
+		reported = append(reported, staleTask)
+	}
+	return reported, nil
+}
+
+func staleTasksMessage(tasks []StaleTask) string {
+	var b strings.Builder
+	for _, task := range tasks {
+		switch {
+		case task.Error != "":
+			fmt.Fprintf(&b, "Could not cancel export task %s of log group %s in account %s, region %s (%s for %d minutes): %s\n",
+				task.TaskId, task.LogGroupName, task.AccountId, task.Region, task.Status, task.AgeMinutes, task.Error)
+		case task.Cancelled:
+			fmt.Fprintf(&b, "Cancelled export task %s of log group %s in account %s, region %s (%s for %d minutes); item %s is %s after %d attempts\n",
+				task.TaskId, task.LogGroupName, task.AccountId, task.Region, task.Status, task.AgeMinutes, task.ItemName, task.ItemStatus, task.Attempts)
+		default:
+			fmt.Fprintf(&b, "Left export task %s of log group %s in account %s, region %s (%s for %d minutes) running, it was not created by the exporter\n",
+				task.TaskId, task.LogGroupName, task.AccountId, task.Region, task.Status, task.AgeMinutes)
+		}
+	}
+	return b.String()
+}
+
+// recordItemTask stores the ID of the export task started for an item, which
+// maps a stale task back to its item, and extends the lease to expiresAt.
+func recordItemTask(ctx context.Context, region, name, owner, taskID string, expiresAt time.Time) error {
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]dynamodbtypes.AttributeValue{
+			"Region": &dynamodbtypes.AttributeValueMemberS{Value: region},
+			"Name":   &dynamodbtypes.AttributeValueMemberS{Value: name},
+		},
+		UpdateExpression: aws.String("SET TaskId = :taskid, LeaseExpiresAt = :expiry REMOVE TaskStatus, BusySince"),
+		ExpressionAttributeValues: map[string]dynamodbtypes.AttributeValue{
+			":taskid": &dynamodbtypes.AttributeValueMemberS{Value: taskID},
+			":expiry": &dynamodbtypes.AttributeValueMemberS{Value: expiresAt.UTC().Format(time.RFC3339)},
+		},
+	}
+	leaseCondition(input, owner)
+	if _, err := dynamoClient.UpdateItem(ctx, input); err != nil {
+		return fmt.Errorf("error recording export task of log group %s: %w", name, err)
+	}
+	return nil
+}
+
+// taskLeaseExpiry is when the lease on an item with a running export task
+// expires. It outlives maxTaskAgeHours, so that a task stuck after its execution
+// ended is cancelled by cancelStaleTasks before its item is reaped.
+func taskLeaseExpiry(regionConfig RegionConfig, now time.Time) time.Time {
+	return now.Add(time.Duration(regionConfig.MaxTaskAgeHours)*time.Hour + leaseDuration)
+}
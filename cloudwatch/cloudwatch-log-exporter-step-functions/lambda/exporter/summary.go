@@ -0,0 +1,128 @@
+package exporter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	severityInfo    = "INFO"
+	severityWarning = "WARNING"
+	severityError   = "ERROR"
+	// maxSummaryExports keeps the digest well below the SNS message size limit.
+	maxSummaryExports = 200
+)
+
+// RunSummary is the digest published once at the end of a run.
+type RunSummary struct {
+	RunId           string             `json:"runId"`
+	Severity        string             `json:"severity"`
+	StartedAt       time.Time          `json:"startedAt,omitempty"`
+	FinishedAt      time.Time          `json:"finishedAt"`
+	DurationSeconds int64              `json:"durationSeconds,omitempty"`
+	Exports         int                `json:"exports"`
+	Totals          map[string]int     `json:"totals"`
+	BytesExported   int64              `json:"bytesExported"`
+	Failed          []SummarizedExport `json:"failed"`
+	Suspect         []SummarizedExport `json:"suspect"`
+	// Omitted counts the failed and suspect exports left out of the digest.
+	Omitted int `json:"omitted,omitempty"`
+}
+
+type SummarizedExport struct {
+	Region       string `json:"region"`
+	AccountId    string `json:"accountId"`
+	LogGroupName string `json:"logGroupName"`
+	TaskId       string `json:"taskId"`
+	Status       string `json:"status"`
+	ItemStatus   string `json:"itemStatus,omitempty"`
+	Reason       string `json:"reason,omitempty"`
+}
+
+// summarizeRun publishes one JSON digest of a run instead of a message per
+// failure. The severity is ERROR when a log group was dead-lettered, WARNING for
+// other failed or suspect exports and INFO otherwise. It routes the digest and
+// is set as the "severity" SNS message attribute, so subscriptions can filter on it.
+func summarizeRun(ctx context.Context, event Event) (RunSummary, error) {
+	report, err := queryRunReport(ctx, event.RunId)
+	if err != nil {
+		return RunSummary{}, err
+	}
+
+	summary := RunSummary{
+		RunId:      report.RunId,
+		Severity:   severityInfo,
+		FinishedAt: time.Now().UTC(),
+		Exports:    report.Total,
+		Totals:     report.Totals,
+		Failed:     []SummarizedExport{},
+		Suspect:    []SummarizedExport{},
+	}
+	if startedAt, err := time.Parse(time.RFC3339, event.RunStartedAt); err == nil {
+		summary.StartedAt = startedAt.UTC()
+		summary.DurationSeconds = int64(summary.FinishedAt.Sub(startedAt).Seconds())
+	}
+
+	failed := 0
+	for _, export := range report.Exports {
+		summary.BytesExported += export.Bytes
+		summarized := SummarizedExport{
+			Region:       export.Region,
+			AccountId:    export.AccountId,
+			LogGroupName: export.LogGroupName,
+			TaskId:       export.TaskId,
+			Status:       export.ExportStatus,
+			ItemStatus:   export.ItemStatus,
+		}
+
+		switch {
+		case export.ExportStatus != "COMPLETED" && export.ExportStatus != "RUNNING":
+			summarized.Reason = export.Error
+			failed++
+			if export.ItemStatus == "DEAD_LETTER" {
+				summary.Severity = severityError
+			} else if summary.Severity == severityInfo {
+				summary.Severity = severityWarning
+			}
+			if len(summary.Failed)+len(summary.Suspect) >= maxSummaryExports {
+				summary.Omitted++
+				continue
+			}
+			summary.Failed = append(summary.Failed, summarized)
+		case export.Verification == "SUSPECT":
+			summarized.Reason = export.VerificationReason
+			if summary.Severity == severityInfo {
+				summary.Severity = severityWarning
+			}
+			if len(summary.Failed)+len(summary.Suspect) >= maxSummaryExports {
+				summary.Omitted++
+				continue
+			}
+			summary.Suspect = append(summary.Suspect, summarized)
+		}
+	}
+
+	message, err := json.Marshal(summary)
+	if err != nil {
+		return RunSummary{}, fmt.Errorf("error marshalling run summary: %w", err)
+	}
+	err = notify(ctx, event.TopicArn, Notification{
+		Severity: summary.Severity,
+		Subject:  fmt.Sprintf("[%s] CloudWatch log export run %s", summary.Severity, summary.RunId),
+		Message:  string(message),
+		Attributes: map[string]interface{}{
+			"runId":  summary.RunId,
+			"failed": failed,
+		},
+		Data: summary,
+	})
+	if err != nil {
+		return RunSummary{}, err
+	}
+
+	log.Printf("Published %s summary of run %s: %d exports, %d failed, %d suspect", summary.Severity, summary.RunId, summary.Exports, failed, len(summary.Suspect))
+	return summary, nil
+}
@@ -0,0 +1,84 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dynamodbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	// suspectRatio is the share of the expected bytes below which an export is
+	// SUSPECT. Exports are gzip-compressed, so they are expected to be smaller
+	// than the stored bytes, but not by orders of magnitude.
+	suspectRatio = 0.01
+	// minExpectedBytes keeps small log groups, whose estimate is mostly noise,
+	// from being flagged for their size alone.
+	minExpectedBytes = 10 << 20
+)
+
+// Verification compares what an export task wrote with what the log group
+// suggests it should have written.
+type Verification struct {
+	Status        string `json:"status"`
+	Reason        string `json:"reason,omitempty"`
+	Objects       int    `json:"objects"`
+	Bytes         int64  `json:"bytes"`
+	ExpectedBytes int64  `json:"expectedBytes,omitempty"`
+}
+
+// verifyExport flags exports without objects, or with far less data than the
+// stored bytes of the window suggest, as SUSPECT. Both point at a bucket policy
+// or KMS key that CloudWatch Logs cannot write with although the task COMPLETED.
+// Quiet windows legitimately export nothing, so an export is only flagged when
+// at least minExpectedBytes were expected. updateDynamoDB retries SUSPECT
+// exports like failed ones.
+func verifyExport(group *types.LogGroup, from, to time.Time, objects int, bytes int64, now time.Time) Verification {
+	v := Verification{Status: "OK", Objects: objects, Bytes: bytes}
+	if expected, ok := estimateWindowBytes(group, from, to, now); ok {
+		v.ExpectedBytes = expected
+	}
+
+	switch {
+	case v.ExpectedBytes < minExpectedBytes:
+		// Too little is expected to tell a quiet window from a lost export
+	case objects == 0:
+		v.Status = "SUSPECT"
+		v.Reason = fmt.Sprintf("export wrote no objects, expected about %d bytes", v.ExpectedBytes)
+	case float64(bytes) < float64(v.ExpectedBytes)*suspectRatio:
+		v.Status = "SUSPECT"
+		v.Reason = fmt.Sprintf("export wrote %d bytes, expected about %d", bytes, v.ExpectedBytes)
+	}
+	return v
+}
+
+// recordVerification stores the verification of the last export, the task
+// taskID, on the item.
+func recordVerification(ctx context.Context, region, name, taskID string, v Verification, now time.Time) error {
+	_, err := dynamoClient.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]dynamodbtypes.AttributeValue{
+			"Region": &dynamodbtypes.AttributeValueMemberS{Value: region},
+			"Name":   &dynamodbtypes.AttributeValueMemberS{Value: name},
+		},
+		UpdateExpression: aws.String("SET Verification = :status, VerificationReason = :reason, VerifiedObjects = :objects, VerifiedBytes = :bytes, ExpectedBytes = :expected, VerifiedAt = :now, VerifiedTaskId = :taskid"),
+		ExpressionAttributeValues: map[string]dynamodbtypes.AttributeValue{
+			":status":   &dynamodbtypes.AttributeValueMemberS{Value: v.Status},
+			":reason":   &dynamodbtypes.AttributeValueMemberS{Value: v.Reason},
+			":objects":  &dynamodbtypes.AttributeValueMemberN{Value: strconv.Itoa(v.Objects)},
+			":bytes":    &dynamodbtypes.AttributeValueMemberN{Value: strconv.FormatInt(v.Bytes, 10)},
+			":expected": &dynamodbtypes.AttributeValueMemberN{Value: strconv.FormatInt(v.ExpectedBytes, 10)},
+			":now":      &dynamodbtypes.AttributeValueMemberS{Value: now.UTC().Format(time.RFC3339)},
+			":taskid":   &dynamodbtypes.AttributeValueMemberS{Value: taskID},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("error recording verification of log group %s: %w", name, err)
+	}
+	return nil
+}
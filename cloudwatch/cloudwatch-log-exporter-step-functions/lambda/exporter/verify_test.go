@@ -0,0 +1,82 @@
+package exporter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+)
+
+func TestVerifyExport(t *testing.T) {
+	now := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+	from, to := now.AddDate(0, 0, -1), now
+	// 10 GiB over 10 days of retention is 1 GiB for the exported day
+	group := &types.LogGroup{
+		CreationTime:    aws.Int64(now.AddDate(-1, 0, 0).UnixMilli()),
+		RetentionInDays: aws.Int32(10),
+		StoredBytes:     aws.Int64(10 << 30),
+	}
+	small := &types.LogGroup{
+		CreationTime:    aws.Int64(now.AddDate(-1, 0, 0).UnixMilli()),
+		RetentionInDays: aws.Int32(10),
+		StoredBytes:     aws.Int64(50 << 20),
+	}
+
+	tests := []struct {
+		name    string
+		group   *types.LogGroup
+		objects int
+		bytes   int64
+		want    Verification
+	}{
+		{
+			name:    "as expected",
+			group:   group,
+			objects: 3,
+			bytes:   100 << 20,
+			want:    Verification{Status: "OK", Objects: 3, Bytes: 100 << 20, ExpectedBytes: 1 << 30},
+		},
+		{
+			name:  "no objects",
+			group: group,
+			want:  Verification{Status: "SUSPECT", Reason: "export wrote no objects, expected about 1073741824 bytes", ExpectedBytes: 1 << 30},
+		},
+		{
+			name:    "far too few bytes",
+			group:   group,
+			objects: 1,
+			bytes:   1 << 20,
+			want:    Verification{Status: "SUSPECT", Reason: "export wrote 1048576 bytes, expected about 1073741824", Objects: 1, Bytes: 1 << 20, ExpectedBytes: 1 << 30},
+		},
+		{
+			name:    "small log group",
+			group:   small,
+			objects: 1,
+			bytes:   10,
+			want:    Verification{Status: "OK", Objects: 1, Bytes: 10, ExpectedBytes: 5 << 20},
+		},
+		{
+			name:    "unknown log group",
+			objects: 1,
+			bytes:   10,
+			want:    Verification{Status: "OK", Objects: 1, Bytes: 10},
+		},
+		{
+			name:  "quiet window without objects",
+			group: small,
+			want:  Verification{Status: "OK", ExpectedBytes: 5 << 20},
+		},
+		{
+			name: "unknown log group without objects",
+			want: Verification{Status: "OK"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := verifyExport(tt.group, from, to, tt.objects, tt.bytes, now); got != tt.want {
+				t.Errorf("verifyExport() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -17,11 +17,26 @@ export class CloudwatchLogExporterStepFunctionsStack extends cdk.Stack {
     constructor(scope: Construct, id: string, props?: cdk.StackProps) {
         super(scope, id, props);
 
+        // Exporter configuration. The bucket and key grants of the Lambda are derived from it,
+        // so a bucket added to the parameter outside this stack also needs a redeploy.
+        const exporterConfig = {
+            version: 1,
+            prefixTemplate: '{logGroup}/{yyyy}/{mm}/{dd}',
+            regions: [
+                { region: 'us-east-1', bucket: 's3://aaa' },
+                { region: 'us-east-2', bucket: 's3://bbb' },
+            ],
+        };
+        const bucketNames = [...new Set(exporterConfig.regions.map(r => r.bucket.replace(/^s3:\/\//, '').split('/')[0]))];
+        const configuredRegions = [...new Set(exporterConfig.regions.map(r => r.region))];
+        const bucketArns = bucketNames.map(bucket => `arn:aws:s3:::${bucket}`);
+        const keyArns = configuredRegions.map(region => `arn:aws:kms:${region}:*:key/*`);
+
         // Create SSM Parameter
         const regionBucketParam = new ssm.StringParameter(this, 'RegionBucketParam', {
             parameterName: '/cloudwatch-log-exporter/region-bucket-map',
-            stringValue: 'us-east-1,s3://aaa\nus-east-2,s3://bbb',
-            description: 'Mapping of regions to S3 buckets for CloudWatch log export',
+            stringValue: JSON.stringify(exporterConfig, null, 2),
+            description: 'Exporter configuration (JSON, version 1) with the destination bucket of each region',
         });
 
         // Create SNS Topic for failed exports
@@ -42,6 +57,12 @@ export class CloudwatchLogExporterStepFunctionsStack extends cdk.Stack {
             default: 'cron(5 0 * * ? *)', // UTC
         });
 
+        const reconcileScheduleParameter = new cdk.CfnParameter(this, 'ReconcileScheduleParameter', {
+            type: 'String',
+            description: 'The cron schedule of the log group inventory reconciliation',
+            default: 'cron(0 6 ? * MON *)', // UTC
+        });
+
         const exportDaysParameter = new cdk.CfnParameter(this, 'ExportDaysParameter', {
             type: 'Number',
             description: 'Number of days of logs to export',
@@ -49,6 +70,12 @@ export class CloudwatchLogExporterStepFunctionsStack extends cdk.Stack {
             minValue: 1,
         });
 
+        const memberAccountRoleName = new cdk.CfnParameter(this, 'MemberAccountRoleName', {
+            type: 'String',
+            description: 'The role assumed in member accounts listed in the exporter configuration',
+            default: 'CloudWatchLogExporterRole',
+        });
+
         // Create DynamoDB table
         const table = new dynamodb.Table(this, 'ExportTasksTable', {
             partitionKey: { name: 'Region', type: dynamodb.AttributeType.STRING },
@@ -62,13 +89,31 @@ export class CloudwatchLogExporterStepFunctionsStack extends cdk.Stack {
             partitionKey: { name: 'ItemStatus', type: dynamodb.AttributeType.STRING },
         });
 
+        table.addGlobalSecondaryIndex({
+            indexName: 'RegionStatusIndex',
+            partitionKey: { name: 'Region', type: dynamodb.AttributeType.STRING },
+            sortKey: { name: 'ItemStatus', type: dynamodb.AttributeType.STRING },
+        });
+
+        // Export tasks of every run, keyed by the execution name of the run
+        const runsTable = new dynamodb.Table(this, 'ExportRunsTable', {
+            partitionKey: { name: 'RunId', type: dynamodb.AttributeType.STRING },
+            sortKey: { name: 'TaskId', type: AttributeType.STRING },
+            billingMode: dynamodb.BillingMode.PAY_PER_REQUEST,
+            removalPolicy: RemovalPolicy.DESTROY,
+        });
+
         // Create Lambda function
         const exportLambda = new lambda.Function(this, 'ExportLambda', {
             runtime: lambda.Runtime.PROVIDED_AL2023,
             architecture: lambda.Architecture.ARM_64,
             handler: 'bootstrap',
             timeout: cdk.Duration.minutes(10),
+            // The prefix module lives beside both exporters. It is mounted where the
+            // replace directive in go.mod resolves from /asset-input, and the
+            // asset is hashed on its output so changes to it are deployed too.
             code: lambda.Code.fromAsset(path.join(__dirname, '../lambda'), {
+                assetHashType: cdk.AssetHashType.OUTPUT,
                 bundling: {
                     image: lambda.Runtime.PROVIDED_AL2023.bundlingImage,
                     command: [
@@ -83,56 +128,158 @@ export class CloudwatchLogExporterStepFunctionsStack extends cdk.Stack {
                         ].join(' && ')
                     ],
                     user: 'root',
+                    volumes: [{
+                        hostPath: path.join(__dirname, '../../prefix'),
+                        containerPath: '/prefix',
+                    }],
                 },
             }),
             environment: {
                 DYNAMODB_TABLE_NAME: table.tableName,
+                RUNS_TABLE_NAME: runsTable.tableName,
                 SSM_PARAM_NAME: regionBucketParam.parameterName,
                 EXPORT_DAYS: exportDaysParameter.valueAsString,
                 SNS_TOPIC_ARN: failedExportsTopic.topicArn,
+                LEASE_DURATION_MINUTES: '360',
+                MEMBER_ACCOUNT_ROLE_NAME: memberAccountRoleName.valueAsString,
             },
         });
 
         // Grant permissions to Lambda
         table.grantReadWriteData(exportLambda);
+        runsTable.grantReadWriteData(exportLambda);
         regionBucketParam.grantRead(exportLambda);
+        // HMAC secrets of webhook notification targets are SecureString parameters below this path
+        exportLambda.addToRolePolicy(new iam.PolicyStatement({
+            actions: ['ssm:GetParameter'],
+            resources: [`arn:aws:ssm:${this.region}:${this.account}:parameter/cloudwatch-log-exporter/*`],
+        }));
         failedExportsTopic.grantPublish(exportLambda);
         exportLambda.addToRolePolicy(new iam.PolicyStatement({
             actions: [
                 'logs:DescribeLogGroups',
+                'logs:DescribeLogStreams',
                 'logs:CreateExportTask',
                 'logs:DescribeExportTasks',
+                'logs:CancelExportTask',
                 'logs:ListTagsForResource',
             ],
             resources: ['*'],
         }));
+        exportLambda.addToRolePolicy(new iam.PolicyStatement({
+            actions: ['sts:AssumeRole'],
+            resources: [`arn:aws:iam::*:role/${memberAccountRoleName.valueAsString}`],
+        }));
         exportLambda.addToRolePolicy(new iam.PolicyStatement({
             actions: ['s3:PutObject'],
-            resources: ['arn:aws:s3:::*/*'],
+            resources: bucketArns.map(arn => `${arn}/*`),
+        }));
+        // Export manifests list the objects of a task and may be encrypted with the region's KMS key
+        exportLambda.addToRolePolicy(new iam.PolicyStatement({
+            actions: ['s3:ListBucket'],
+            resources: bucketArns,
+        }));
+        exportLambda.addToRolePolicy(new iam.PolicyStatement({
+            actions: ['kms:GenerateDataKey'],
+            resources: keyArns,
+            conditions: {
+                StringEquals: {
+                    'kms:ViaService': configuredRegions.map(region => `s3.${region}.amazonaws.com`),
+                },
+            },
+        }));
+        // The preflight reads the location, policy and default encryption of each bucket and its key
+        exportLambda.addToRolePolicy(new iam.PolicyStatement({
+            actions: ['s3:GetBucketLocation', 's3:GetBucketPolicy', 's3:GetEncryptionConfiguration'],
+            resources: bucketArns,
+        }));
+        exportLambda.addToRolePolicy(new iam.PolicyStatement({
+            actions: ['kms:DescribeKey', 'kms:GetKeyPolicy'],
+            resources: keyArns,
         }));
 
         // Define Step Functions tasks
+        // The Lambda reports errors by kind (see lambda/exporter/errors.go). Throttles and limits
+        // clear up when retried later; every other kind is caught and notified.
+        const throttledRetry: sfn.RetryProps = {
+            errors: ['Exporter.Throttled'],
+            interval: cdk.Duration.seconds(30),
+            backoffRate: 2,
+            maxAttempts: 5,
+        };
+
         const sendNotification = new tasks.LambdaInvoke(this, 'SendNotification', {
             lambdaFunction: exportLambda,
             payload: sfn.TaskInput.fromObject({
                 action: 'sendNotification',
-                topicArn: failedExportsTopic.topicArn,
+                severity: 'ERROR',
+                message: sfn.JsonPath.stringAt('$.error'),
+            }),
+        });
+
+        // States inside the Map iterator cannot share the outer catch target
+        const sendLaneNotification = new tasks.LambdaInvoke(this, 'SendLaneNotification', {
+            lambdaFunction: exportLambda,
+            payload: sfn.TaskInput.fromObject({
+                action: 'sendNotification',
+                severity: 'ERROR',
                 message: sfn.JsonPath.stringAt('$.error'),
             }),
         });
 
+        const reapExpiredLeases = new tasks.LambdaInvoke(this, 'ReapExpiredLeases', {
+            lambdaFunction: exportLambda,
+            payload: sfn.TaskInput.fromObject({ action: 'reapExpiredLeases' }),
+            resultPath: sfn.JsonPath.DISCARD,
+        }).addRetry(throttledRetry).addCatch(sendNotification, {
+            resultPath: '$.error',
+        });
+
+        // Frees export slots held by tasks stuck for longer than maxTaskAgeHours, e.g.
+        // by an execution that timed out, and requeues their log groups. Runs before
+        // the leases are reaped, while the items still hold their task's lease
+        const cancelStaleTasks = new tasks.LambdaInvoke(this, 'CancelStaleTasks', {
+            lambdaFunction: exportLambda,
+            payload: sfn.TaskInput.fromObject({ action: 'cancelStaleTasks' }),
+            resultPath: sfn.JsonPath.DISCARD,
+        }).addRetry(throttledRetry).addCatch(sendNotification, {
+            resultPath: '$.error',
+        });
+
+        // Halts the run before any export task is created when a destination bucket is in the
+        // wrong region, does not let CloudWatch Logs write, or uses a key it cannot use
+        const preflightBuckets = new tasks.LambdaInvoke(this, 'PreflightBuckets', {
+            lambdaFunction: exportLambda,
+            payload: sfn.TaskInput.fromObject({ action: 'preflightBuckets' }),
+            resultPath: '$.preflightResult',
+        }).addRetry(throttledRetry).addCatch(sendNotification, {
+            resultPath: '$.error',
+        });
+
         const listLogGroups = new tasks.LambdaInvoke(this, 'ListLogGroups', {
             lambdaFunction: exportLambda,
             payload: sfn.TaskInput.fromObject({ action: 'listLogGroups' }),
-        }).addCatch(sendNotification, {
+        }).addRetry(throttledRetry).addCatch(sendNotification, {
+            resultPath: '$.error',
+        });
+
+        const listPendingRegions = new tasks.LambdaInvoke(this, 'ListPendingRegions', {
+            lambdaFunction: exportLambda,
+            payload: sfn.TaskInput.fromObject({ action: 'listPendingRegions' }),
+            resultPath: '$.pendingRegionsResult',
+        }).addRetry(throttledRetry).addCatch(sendNotification, {
             resultPath: '$.error',
         });
 
         const getNextLogGroup = new tasks.LambdaInvoke(this, 'GetNextLogGroup', {
             lambdaFunction: exportLambda,
-            payload: sfn.TaskInput.fromObject({ action: 'getNextLogGroup' }),
+            payload: sfn.TaskInput.fromObject({
+                action: 'getNextLogGroup',
+                region: sfn.JsonPath.stringAt('$.region'),
+                owner: sfn.JsonPath.stringAt('$$.Execution.Id'),
+            }),
             resultPath: '$.logGroupResult',
-        }).addCatch(sendNotification, {
+        }).addRetry(throttledRetry).addCatch(sendLaneNotification, {
             resultPath: '$.error',
         });
 
@@ -140,10 +287,11 @@ export class CloudwatchLogExporterStepFunctionsStack extends cdk.Stack {
             lambdaFunction: exportLambda,
             payload: sfn.TaskInput.fromObject({
                 action: 'checkRunningTasks',
-                region: sfn.JsonPath.stringAt('$.logGroupResult.Payload.region'),
+                accountId: sfn.JsonPath.stringAt('$.logGroupResult.Payload.accountId'),
+                region: sfn.JsonPath.stringAt('$.region'),
             }),
             resultPath: '$.checkTasksResult',
-        }).addCatch(sendNotification, {
+        }).addRetry(throttledRetry).addCatch(sendLaneNotification, {
             resultPath: '$.error',
         });
 
@@ -151,11 +299,15 @@ export class CloudwatchLogExporterStepFunctionsStack extends cdk.Stack {
             lambdaFunction: exportLambda,
             payload: sfn.TaskInput.fromObject({
                 action: 'createExportTask',
-                logGroupName: sfn.JsonPath.stringAt('$.logGroupResult.Payload.name'),
-                region: sfn.JsonPath.stringAt('$.logGroupResult.Payload.region'),
+                owner: sfn.JsonPath.stringAt('$$.Execution.Id'),
+                runId: sfn.JsonPath.stringAt('$$.Execution.Name'),
+                accountId: sfn.JsonPath.stringAt('$.logGroupResult.Payload.accountId'),
+                logGroupName: sfn.JsonPath.stringAt('$.logGroupResult.Payload.logGroupName'),
+                itemName: sfn.JsonPath.stringAt('$.logGroupResult.Payload.name'),
+                region: sfn.JsonPath.stringAt('$.region'),
             }),
             resultPath: '$.createTaskResult',
-        }).addCatch(sendNotification, {
+        }).addRetry(throttledRetry).addCatch(sendLaneNotification, {
             resultPath: '$.error',
         });
 
@@ -163,45 +315,70 @@ export class CloudwatchLogExporterStepFunctionsStack extends cdk.Stack {
             lambdaFunction: exportLambda,
             payload: sfn.TaskInput.fromObject({
                 action: 'checkExportTaskStatus',
+                accountId: sfn.JsonPath.stringAt('$.logGroupResult.Payload.accountId'),
                 taskId: sfn.JsonPath.stringAt('$.createTaskResult.Payload.taskId'),
-                region: sfn.JsonPath.stringAt('$.logGroupResult.Payload.region'),
+                region: sfn.JsonPath.stringAt('$.region'),
             }),
             resultPath: '$.checkStatusResult',
-        }).addCatch(sendNotification, {
+        }).addRetry(throttledRetry).addCatch(sendLaneNotification, {
             resultPath: '$.error',
         });
 
-        const notifyFailure = new tasks.LambdaInvoke(this, 'NotifyFailure', {
+        const writeManifest = new tasks.LambdaInvoke(this, 'WriteManifest', {
             lambdaFunction: exportLambda,
             payload: sfn.TaskInput.fromObject({
-                action: 'sendNotification',
-                topicArn: failedExportsTopic.topicArn,
-                message: sfn.JsonPath.format('Export task failed for log group {} in region {}. Task ID: {}, Status: {}, Start Time: {}',
-                    sfn.JsonPath.stringAt('$.logGroupResult.Payload.name'),
-                    sfn.JsonPath.stringAt('$.logGroupResult.Payload.region'),
-                    sfn.JsonPath.stringAt('$.createTaskResult.Payload.taskId'),
-                    sfn.JsonPath.stringAt('$.checkStatusResult.Payload.status'),
-                    sfn.JsonPath.stringAt('$.checkStatusResult.Payload.startTime')
-                ),
+                action: 'writeManifest',
+                runId: sfn.JsonPath.stringAt('$$.Execution.Name'),
+                accountId: sfn.JsonPath.stringAt('$.logGroupResult.Payload.accountId'),
+                logGroupName: sfn.JsonPath.stringAt('$.logGroupResult.Payload.logGroupName'),
+                itemName: sfn.JsonPath.stringAt('$.logGroupResult.Payload.name'),
+                taskId: sfn.JsonPath.stringAt('$.createTaskResult.Payload.taskId'),
+                region: sfn.JsonPath.stringAt('$.region'),
             }),
+            resultPath: '$.manifestResult',
+        }).addRetry(throttledRetry).addCatch(sendLaneNotification, {
+            resultPath: '$.error',
         });
 
         const updateDynamoDB = new tasks.LambdaInvoke(this, 'UpdateDynamoDB', {
             lambdaFunction: exportLambda,
             payload: sfn.TaskInput.fromObject({
                 action: 'updateDynamoDB',
-                logGroupName: sfn.JsonPath.stringAt('$.logGroupResult.Payload.name'),
-                region: sfn.JsonPath.stringAt('$.logGroupResult.Payload.region'),
-                itemStatus: sfn.JsonPath.stringAt('$.checkStatusResult.Payload.status'),
+                owner: sfn.JsonPath.stringAt('$$.Execution.Id'),
+                runId: sfn.JsonPath.stringAt('$$.Execution.Name'),
+                accountId: sfn.JsonPath.stringAt('$.logGroupResult.Payload.accountId'),
+                logGroupName: sfn.JsonPath.stringAt('$.logGroupResult.Payload.logGroupName'),
+                itemName: sfn.JsonPath.stringAt('$.logGroupResult.Payload.name'),
+                region: sfn.JsonPath.stringAt('$.region'),
+                status: sfn.JsonPath.stringAt('$.checkStatusResult.Payload.status.Code'),
+                message: sfn.JsonPath.stringAt('$.checkStatusResult.Payload.status.Message'),
                 taskId: sfn.JsonPath.stringAt('$.createTaskResult.Payload.taskId'),
                 startTime: sfn.JsonPath.stringAt('$.checkStatusResult.Payload.startTime'),
                 endTime: sfn.JsonPath.stringAt('$.checkStatusResult.Payload.endTime'),
             }),
-        }).addCatch(sendNotification, {
+            resultPath: '$.updateResult',
+        }).addRetry(throttledRetry).addCatch(sendLaneNotification, {
             resultPath: '$.error',
         });
 
-
+        // One digest per run instead of a message per failed export; the execution output is its headline
+        const summarizeRun = new tasks.LambdaInvoke(this, 'SummarizeRun', {
+            lambdaFunction: exportLambda,
+            payload: sfn.TaskInput.fromObject({
+                action: 'summarizeRun',
+                runId: sfn.JsonPath.stringAt('$$.Execution.Name'),
+                runStartedAt: sfn.JsonPath.stringAt('$$.Execution.StartTime'),
+            }),
+            resultSelector: {
+                runId: sfn.JsonPath.stringAt('$.Payload.runId'),
+                severity: sfn.JsonPath.stringAt('$.Payload.severity'),
+                exports: sfn.JsonPath.numberAt('$.Payload.exports'),
+                totals: sfn.JsonPath.objectAt('$.Payload.totals'),
+            },
+            resultPath: '$.runSummary',
+        }).addRetry(throttledRetry).addCatch(sendNotification, {
+            resultPath: '$.error',
+        });
 
         // Define wait states
         const wait30SecondsForTasks = new sfn.Wait(this, 'Wait30SecondsForTasks', {
@@ -216,9 +393,14 @@ export class CloudwatchLogExporterStepFunctionsStack extends cdk.Stack {
             time: sfn.WaitTime.duration(cdk.Duration.seconds(3)),
         });
 
-        // Define Step Functions workflow
-        const definition = listLogGroups
-            .next(getNextLogGroup)
+        // The Lambda estimates how long the export task blocking the region keeps running
+        const waitForBlockingTask = new sfn.Wait(this, 'WaitForBlockingTask', {
+            time: sfn.WaitTime.secondsPath('$.createTaskResult.Payload.waitSeconds'),
+        });
+
+        // Each lane drains the PENDING items of one region, since the export task
+        // limit applies per account per region
+        const exportLane = getNextLogGroup
             .next(new sfn.Choice(this, 'LogGroupAvailable')
                 .when(sfn.Condition.isPresent('$.logGroupResult.Payload.name'),
                     checkRunningTasks
@@ -227,27 +409,63 @@ export class CloudwatchLogExporterStepFunctionsStack extends cdk.Stack {
                                 wait30SecondsForTasks.next(checkRunningTasks))
                             .otherwise(
                                 createExportTask
-                                    .next(wait30SecondsForExport)
-                                    .next(checkExportTaskStatus)
-                                    .next(new sfn.Choice(this, 'ExportTaskStatus')
-                                        .when(sfn.Condition.stringEquals('$.checkStatusResult.Payload.status.Code', 'COMPLETED'),
-                                            updateDynamoDB)
-                                        .when(sfn.Condition.or(
-                                            sfn.Condition.stringEquals('$.checkStatusResult.Payload.status.Code', 'CANCELLED'),
-                                            sfn.Condition.stringEquals('$.checkStatusResult.Payload.status.Code', 'FAILED'),
-                                            sfn.Condition.stringEquals('$.checkStatusResult.Payload.status.Code', 'PENDING_CANCEL')
-                                        ), notifyFailure)
-                                        .otherwise(wait30SecondsForStatus.next(checkExportTaskStatus))
+                                    .next(new sfn.Choice(this, 'ExportTaskCreated')
+                                        // Another export of the account is active in the region; the item keeps its lease
+                                        .when(sfn.Condition.and(
+                                            sfn.Condition.isPresent('$.createTaskResult.Payload.busy'),
+                                            sfn.Condition.booleanEquals('$.createTaskResult.Payload.busy', true)
+                                        ), waitForBlockingTask.next(createExportTask))
+                                        // The item waited too long for the region and is PENDING again; the
+                                        // remaining items of the region would only wait as well
+                                        .when(sfn.Condition.and(
+                                            sfn.Condition.isPresent('$.createTaskResult.Payload.deferred'),
+                                            sfn.Condition.booleanEquals('$.createTaskResult.Payload.deferred', true)
+                                        ), new sfn.Succeed(this, 'RegionLaneDeferred'))
+                                        // No task is created when the log group is already exported through the last day boundary
+                                        .when(sfn.Condition.isNotPresent('$.createTaskResult.Payload.taskId'),
+                                            getNextLogGroup)
+                                        .otherwise(wait30SecondsForExport
+                                            .next(checkExportTaskStatus)
+                                            .next(new sfn.Choice(this, 'ExportTaskStatus')
+                                                .when(sfn.Condition.stringEquals('$.checkStatusResult.Payload.status.Code', 'COMPLETED'),
+                                                    writeManifest.next(updateDynamoDB))
+                                                .when(sfn.Condition.or(
+                                                    sfn.Condition.stringEquals('$.checkStatusResult.Payload.status.Code', 'CANCELLED'),
+                                                    sfn.Condition.stringEquals('$.checkStatusResult.Payload.status.Code', 'FAILED'),
+                                                    sfn.Condition.stringEquals('$.checkStatusResult.Payload.status.Code', 'PENDING_CANCEL')
+                                                ), updateDynamoDB)
+                                                .otherwise(wait30SecondsForStatus.next(checkExportTaskStatus))
+                                            )
+                                        )
                                     )
                             )
                         )
                 )
-                .otherwise(new sfn.Succeed(this, 'AllLogGroupsProcessed'))
+                .otherwise(new sfn.Succeed(this, 'RegionLaneDrained'))
             );
 
-        notifyFailure.next(updateDynamoDB);
+        // Failed exports are requeued with a delay and reported in the run summary
         updateDynamoDB.next(getNextLogGroup);
 
+        const exportLanes = new sfn.Map(this, 'ExportLanes', {
+            itemsPath: '$.pendingRegionsResult.Payload.regions',
+            itemSelector: {
+                region: sfn.JsonPath.stringAt('$$.Map.Item.Value'),
+            },
+            resultPath: sfn.JsonPath.DISCARD,
+        });
+        exportLanes.itemProcessor(exportLane);
+
+        // Define Step Functions workflow
+        const definition = cancelStaleTasks
+            .next(reapExpiredLeases)
+            .next(preflightBuckets)
+            .next(listLogGroups)
+            .next(listPendingRegions)
+            .next(exportLanes)
+            .next(summarizeRun)
+            .next(new sfn.Succeed(this, 'AllLogGroupsProcessed'));
+
         // Create Step Functions state machine
         const stateMachine = new sfn.StateMachine(this, 'ExportStateMachine', {
             definitionBody: sfn.DefinitionBody.fromChainable(definition),
@@ -259,5 +477,15 @@ export class CloudwatchLogExporterStepFunctionsStack extends cdk.Stack {
             schedule: events.Schedule.expression(scheduleParameter.valueAsString),
             targets: [new targets.SfnStateMachine(stateMachine)],
         });
+
+        // Mark deleted log groups and report new ones once a week
+        new events.Rule(this, 'WeeklyReconcileRule', {
+            schedule: events.Schedule.expression(reconcileScheduleParameter.valueAsString),
+            targets: [new targets.LambdaFunction(exportLambda, {
+                event: events.RuleTargetInput.fromObject({
+                    action: 'reconcileLogGroups',
+                }),
+            })],
+        });
     }
 }
\ No newline at end of file
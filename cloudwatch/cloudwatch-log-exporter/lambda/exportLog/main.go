@@ -6,11 +6,23 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-lambda-go/lambdacontext"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+
+	"prefix"
+)
+
+// Every prefix is placed below the root, which the function's s3:PutObject
+// grant is scoped to. The template defaults to the step-functions exporter's,
+// so both archives share one layout.
+const (
+	defaultPrefixRoot     = "exportedlogs"
+	defaultPrefixTemplate = prefix.Default
 )
 
 var client *cloudwatchlogs.Client
@@ -100,6 +112,27 @@ func handler(ctx context.Context) error {
 		return fmt.Errorf("DESTINATION_BUCKET environment variable not set")
 	}
 
+	prefixRoot := strings.Trim(os.Getenv("DESTINATION_PREFIX"), "/")
+	if prefixRoot == "" {
+		prefixRoot = defaultPrefixRoot
+	}
+	prefixTemplate := os.Getenv("DESTINATION_PREFIX_TEMPLATE")
+	if prefixTemplate == "" {
+		prefixTemplate = defaultPrefixTemplate
+	}
+	if problems := prefix.Validate(prefixTemplate); len(problems) > 0 {
+		return fmt.Errorf("invalid DESTINATION_PREFIX_TEMPLATE: %s", strings.Join(problems, "; "))
+	}
+
+	// The request ID identifies the run in the {runId} token
+	fields := prefix.Fields{Region: os.Getenv("AWS_REGION")}
+	if lc, ok := lambdacontext.FromContext(ctx); ok {
+		fields.RunID = lc.AwsRequestID
+		if arnParts := strings.Split(lc.InvokedFunctionArn, ":"); len(arnParts) >= 5 {
+			fields.Account = arnParts[4]
+		}
+	}
+
 	startTimeMs, endTimeMs := getExportTimeRange()
 
 	logGroups, err := listLogGroups(ctx)
@@ -113,10 +146,9 @@ func handler(ctx context.Context) error {
 	for _, logGroupName := range logGroups {
 		<-ticker.C
 
-		startTime := time.Unix(0, startTimeMs*int64(time.Millisecond)).UTC()
-		destinationPrefix := fmt.Sprintf("exportedlogs/%s/year=%d/month=%02d/day=%02d",
-			logGroupName[1:],
-			startTime.Year(), startTime.Month(), startTime.Day())
+		fields.LogGroup = logGroupName
+		fields.Time = time.Unix(0, startTimeMs*int64(time.Millisecond)).UTC()
+		destinationPrefix := prefix.Expand(prefixRoot+"/"+prefixTemplate, fields)
 
 		logger.Printf("Creating export task for %s", logGroupName)
 		logger.Printf("Export time range: %s to %s",
@@ -17,6 +17,22 @@ const destinationBucket = new cdk.CfnParameter(this, 'S3DestinationBucket', {
       default: 'myBucket'
     });
 
+    const destinationPrefix = new cdk.CfnParameter(this, 'DestinationPrefix', {
+      type: 'String',
+      description: 'The fixed prefix every export is written below',
+      noEcho: false,
+      default: 'exportedlogs',
+      allowedPattern: '[^/{}]([^{}]*[^/{}])?',
+      constraintDescription: 'must not start or end with / or contain template tokens',
+    });
+
+    const prefixTemplate = new cdk.CfnParameter(this, 'DestinationPrefixTemplate', {
+      type: 'String',
+      description: 'The destination prefix template below DestinationPrefix, with {account}, {region}, {logGroup}, {logGroupSanitized}, {yyyy}, {mm}, {dd}, {hh} and {runId} tokens',
+      noEcho: false,
+      default: '{logGroup}/{yyyy}/{mm}/{dd}'
+    });
+
     const scheduleParameter = new cdk.CfnParameter(this, 'ScheduleParameter', {
       type: 'String',
       description: 'The cron schedule for the event rule',
@@ -33,6 +49,8 @@ const destinationBucket = new cdk.CfnParameter(this, 'S3DestinationBucket', {
         timeout: cdk.Duration.minutes(15),
         environment: {
             DESTINATION_BUCKET: destinationBucket.valueAsString,
+            DESTINATION_PREFIX: destinationPrefix.valueAsString,
+            DESTINATION_PREFIX_TEMPLATE: prefixTemplate.valueAsString,
         },
     })
 
@@ -51,7 +69,7 @@ const destinationBucket = new cdk.CfnParameter(this, 'S3DestinationBucket', {
 
     const s3PolicyStatement = new iam.PolicyStatement({
       actions: ['s3:PutObject'],
-      resources: [`arn:aws:s3:::${destinationBucket.valueAsString}/exportedlogs/*`],
+      resources: [`arn:aws:s3:::${destinationBucket.valueAsString}/${destinationPrefix.valueAsString}/*`],
     });
 
     exportLogFunction.addToRolePolicy(cloudwatchlogPolicyStatement);
@@ -0,0 +1,3 @@
+module prefix
+
+go 1.22.2
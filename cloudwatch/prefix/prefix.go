@@ -0,0 +1,84 @@
+// Package prefix expands the destination prefix templates of CloudWatch Logs
+// export tasks. Both exporters use it, so their archives share one layout and
+// the same Athena partition projection works against either of them.
+package prefix
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	// Default lays objects out by log group and day.
+	Default = "{logGroup}/{yyyy}/{mm}/{dd}"
+	// Hive lays objects out in Hive-style partitions with the log group as one
+	// path segment, so Athena partition projection can inject it.
+	Hive = "{logGroupSanitized}/year={yyyy}/month={mm}/day={dd}"
+)
+
+var (
+	tokenRegexp  = regexp.MustCompile(`\{[^{}]*\}`)
+	unsafeRegexp = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+	tokens       = map[string]bool{
+		"{account}":           true,
+		"{region}":            true,
+		"{logGroup}":          true,
+		"{logGroupSanitized}": true,
+		"{yyyy}":              true,
+		"{mm}":                true,
+		"{dd}":                true,
+		"{hh}":                true,
+		"{runId}":             true,
+	}
+)
+
+// Fields are the values a template is expanded with. Time is the start of the
+// exported range.
+type Fields struct {
+	Account  string
+	Region   string
+	LogGroup string
+	RunID    string
+	Time     time.Time
+}
+
+// Validate returns a problem for every unknown token of a template.
+func Validate(template string) []string {
+	var problems []string
+	for _, token := range tokenRegexp.FindAllString(template, -1) {
+		if !tokens[token] {
+			problems = append(problems, fmt.Sprintf("unknown token %s", token))
+		}
+	}
+	return problems
+}
+
+// Expand renders a template. Log group names usually start with /, so a slash
+// doubled by the template is collapsed.
+func Expand(template string, f Fields) string {
+	t := f.Time.UTC()
+	prefix := strings.NewReplacer(
+		"{account}", f.Account,
+		"{region}", f.Region,
+		"{logGroupSanitized}", Sanitize(f.LogGroup),
+		"{logGroup}", f.LogGroup,
+		"{yyyy}", t.Format("2006"),
+		"{mm}", t.Format("01"),
+		"{dd}", t.Format("02"),
+		"{hh}", t.Format("15"),
+		"{runId}", f.RunID,
+	).Replace(template)
+	for strings.Contains(prefix, "//") {
+		prefix = strings.ReplaceAll(prefix, "//", "/")
+	}
+	return prefix
+}
+
+// Sanitize turns a log group name into a single path segment that is a valid
+// Hive partition value: the leading slash is dropped and every other run of
+// characters outside [A-Za-z0-9._-] becomes an underscore.
+func Sanitize(logGroupName string) string {
+	return unsafeRegexp.ReplaceAllString(strings.TrimPrefix(logGroupName, "/"), "_")
+}
@@ -0,0 +1,80 @@
+package prefix
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		template string
+		want     []string
+	}{
+		{Default, nil},
+		{Hive, nil},
+		{"", nil},
+		{"exports/{account}/{region}/{logGroupSanitized}/year={yyyy}/month={mm}/day={dd}/hour={hh}/{runId}", nil},
+		{"{logGroup}/{date}", []string{"unknown token {date}"}},
+		{"{LogGroup}/{yyyy}/{}", []string{"unknown token {LogGroup}", "unknown token {}"}},
+		{"{{logGroup}}", nil},
+	}
+	for _, tt := range tests {
+		if got := Validate(tt.template); !slices.Equal(got, tt.want) {
+			t.Errorf("Validate(%q) = %q, want %q", tt.template, got, tt.want)
+		}
+	}
+}
+
+func TestExpand(t *testing.T) {
+	fields := Fields{
+		Account:  "111122223333",
+		Region:   "us-east-1",
+		LogGroup: "/aws/lambda/app",
+		RunID:    "run-1",
+		Time:     time.Date(2026, 3, 7, 5, 30, 0, 0, time.UTC),
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		fields   Fields
+		want     string
+	}{
+		{"default", Default, fields, "/aws/lambda/app/2026/03/07"},
+		{"hive", Hive, fields, "aws_lambda_app/year=2026/month=03/day=07"},
+		{"doubled slash collapsed", "exports/{logGroup}/{yyyy}", fields, "exports/aws/lambda/app/2026"},
+		{"sanitized log group", "{logGroupSanitized}/day={dd}", fields, "aws_lambda_app/day=07"},
+		{"account, region, hour and run", "{account}/{region}/{hh}/{runId}", fields, "111122223333/us-east-1/05/run-1"},
+		{"UTC", "{dd}/{hh}", Fields{Time: time.Date(2026, 3, 7, 23, 0, 0, 0, time.FixedZone("UTC-2", -2*60*60))}, "08/01"},
+		{"empty fields", "{account}/{logGroup}/x", Fields{}, "/x"},
+		{"unknown token kept", "{logGroup}/{date}", fields, "/aws/lambda/app/{date}"},
+		{"no tokens", "static", fields, "static"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Expand(tt.template, tt.fields); got != tt.want {
+				t.Errorf("Expand(%q) = %q, want %q", tt.template, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		logGroupName string
+		want         string
+	}{
+		{"/aws/lambda/app", "aws_lambda_app"},
+		{"app.log-1_a", "app.log-1_a"},
+		{"/aws/api-gateway/my api#1", "aws_api-gateway_my_api_1"},
+		{"a//b", "a_b"},
+		{"//a", "_a"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := Sanitize(tt.logGroupName); got != tt.want {
+			t.Errorf("Sanitize(%q) = %q, want %q", tt.logGroupName, got, tt.want)
+		}
+	}
+}